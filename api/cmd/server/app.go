@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/config"
+	"speaktrainer-api/internal/database"
+	"speaktrainer-api/internal/progress"
+	"speaktrainer-api/internal/services"
+	"speaktrainer-api/internal/storage"
+)
+
+// app holds the dependencies shared by the server and the CLI commands
+type app struct {
+	cfg        *config.Config
+	db         *gorm.DB
+	audioStore storage.AudioStore
+	ml         *services.MLPool
+	// analysisCache is nil when caching is disabled
+	analysisCache *services.AnalysisCache
+	progress      *progress.Bus
+	// progressRelay is set when progress is shared between replicas
+	progressRelay *progress.PostgresRelay
+
+	prompts       *services.PromptService
+	sessions      *services.SessionService
+	leaderboard   *services.LeaderboardService
+	groups        *services.GroupService
+	users         *services.UserService
+	feedback      *services.FeedbackService
+	notifications *services.NotificationService
+	privacy       *services.PrivacyService
+	retention     *services.RetentionService
+	health        *services.HealthService
+	apiKeys       *services.APIKeyService
+	organisations *services.OrganisationService
+	webhooks      *services.WebhookService
+	idempotency   *services.IdempotencyService
+}
+
+func newApp(cfg *config.Config) (*app, error) {
+	// Initialize database
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Raw recordings are only kept when a storage directory is configured
+	var audioStore storage.AudioStore
+	if cfg.AudioStorageDir != "" {
+		fileStore, err := storage.NewFileAudioStore(cfg.AudioStorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize audio storage: %w", err)
+		}
+		audioStore = fileStore
+	}
+
+	backends, err := mlBackends(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize services
+	a := &app{
+		cfg:        cfg,
+		db:         db,
+		audioStore: audioStore,
+		ml:         services.NewMLPool(backends),
+	}
+
+	switch cfg.ProgressBus {
+	case "memory":
+		a.progress = progress.NewBus(nil)
+	case "postgres":
+		a.progressRelay = progress.NewPostgresRelay(db)
+		a.progress = progress.NewBus(a.progressRelay)
+	default:
+		return nil, fmt.Errorf("unknown progress bus %q: use memory or postgres", cfg.ProgressBus)
+	}
+
+	if cfg.AnalysisCacheTTL > 0 {
+		a.analysisCache = services.NewAnalysisCache(db, cfg.AnalysisCacheSize, cfg.AnalysisCacheTTL)
+	}
+
+	a.prompts = services.NewPromptService(db)
+	a.sessions = services.NewSessionService(db, a.ml, audioStore, a.analysisCache, a.progress)
+	a.leaderboard = services.NewLeaderboardService(db)
+	a.groups = services.NewGroupService(db)
+	a.users = services.NewUserService(db)
+	a.feedback = services.NewFeedbackService(db)
+	a.notifications = services.NewNotificationService(db)
+	a.privacy = services.NewPrivacyService(db, audioStore, a.leaderboard, a.analysisCache)
+	a.retention = services.NewRetentionService(db, audioStore, a.analysisCache, services.RetentionPolicy{
+		AudioRetention:    time.Duration(cfg.RetentionAudioDays) * 24 * time.Hour,
+		AnalysisRetention: time.Duration(cfg.RetentionAnalysisDays) * 24 * time.Hour,
+		BatchSize:         cfg.RetentionBatchSize,
+	})
+	a.health = services.NewHealthService(db, a.ml, cfg.HealthCheckTimeout, cfg.HealthCacheDuration)
+	a.apiKeys = services.NewAPIKeyService(db)
+	a.organisations = services.NewOrganisationService(db)
+	a.webhooks = services.NewWebhookService(db, cfg.WebhookTimeout, cfg.WebhookMaxAttempts)
+	a.idempotency = services.NewIdempotencyService(db, cfg.IdempotencyKeyTTL)
+
+	return a, nil
+}
+
+// mlBackends returns the configured ML backends. Without ML_BACKENDS there
+// is one, named default, at ML_SERVICE_URL, serving both model classes.
+func mlBackends(cfg *config.Config) ([]services.MLBackendConfig, error) {
+	if cfg.MLBackends == "" {
+		return []services.MLBackendConfig{{
+			Name:   "default",
+			URL:    cfg.MLServiceURL,
+			Model:  cfg.MLModelVersion,
+			Weight: 1,
+		}}, nil
+	}
+	return services.ParseMLBackends(cfg.MLBackends)
+}
+
+// tenant returns the tenant for an organisation ID given on the command
+// line, or the zero Tenant when it is empty
+func (a *app) tenant(orgID string) (services.Tenant, error) {
+	if orgID == "" {
+		return services.TenantOf(nil), nil
+	}
+	return a.organisations.TenantForOrganisation(orgID)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"speaktrainer-api/internal/config"
+)
+
+const usage = `usage: server [-json] <command> [arguments]
+
+commands:
+  serve                               run the HTTP API (default)
+  migrate up|down [n]|status          manage schema migrations
+  migrate create [-dir d] <name>      write a new migration pair
+  seed [-file f]                      seed prompts into an empty database
+  prompts import [-org id] <file>     add prompts from a .json or .txt file
+  prompts export [-org id] [-file f]  write the global (and org's) prompts as JSON
+  users create -email e -name n [-role r]
+  users promote <id|email> [-role r]  change a user's role (default teacher)
+  users key <id> [-name n] [-scopes s]
+                                      issue an API key acting as the user
+  sessions rescore [-user id] [-since date] [-limit n] [-model m]
+                                      re-run analysis on stored recordings
+  retention sweep [-dry-run]          apply the data retention policy now
+  ml ping                             check each ML backend is reachable
+  openapi check                       fail if a route is missing from openapi.json
+  openapi print                       write the OpenAPI document to stdout
+
+-json prints results and errors as JSON for scripting.
+Exit codes: 0 success, 1 failure, 2 invalid usage.`
+
+// usageError marks mistakes in how a command was invoked (exit code 2)
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// reportedError is a failure the command's JSON result already describes,
+// so -json output stays a single document
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+// cli carries global options into every command
+type cli struct {
+	json   bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command func(c *cli, args []string) error
+
+var commands = map[string]command{
+	"serve":     serveCommand,
+	"migrate":   migrateCommand,
+	"seed":      seedCommand,
+	"prompts":   promptsCommand,
+	"users":     usersCommand,
+	"sessions":  sessionsCommand,
+	"retention": retentionCommand,
+	"ml":        mlCommand,
+	"openapi":   openapiCommand,
+}
+
+// run dispatches to a command and returns the process exit code
+func run(args []string) int {
+	c := &cli{stdout: os.Stdout, stderr: os.Stderr}
+
+	global := flag.NewFlagSet("server", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	global.BoolVar(&c.json, "json", false, "print JSON output")
+	if err := global.Parse(args); err != nil {
+		return c.exit(usagef("%v", err))
+	}
+
+	args = global.Args()
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		fmt.Fprintln(c.stdout, usage)
+		return 0
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		return c.exit(usagef("unknown command %q", name))
+	}
+
+	return c.exit(cmd(c, args))
+}
+
+func (c *cli) exit(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var reported *reportedError
+	switch {
+	case !c.json:
+		fmt.Fprintf(c.stderr, "error: %v\n", err)
+	case !errors.As(err, &reported):
+		json.NewEncoder(c.stdout).Encode(map[string]string{"error": err.Error()})
+	}
+
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		if !c.json {
+			fmt.Fprintln(c.stderr, usage)
+		}
+		return 2
+	}
+	return 1
+}
+
+// result prints v as JSON in -json mode, otherwise the formatted text
+func (c *cli) result(v interface{}, format string, args ...interface{}) {
+	if c.json {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+		return
+	}
+	fmt.Fprintf(c.stdout, format, args...)
+}
+
+// parseArgs parses flags that may appear before or after positional
+// arguments and returns the positional ones
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usagef("%s: %v", fs.Name(), err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// loadApp loads configuration and connects the services a command needs
+func loadApp() (*app, error) {
+	return newApp(config.Load())
+}
+
+func serveCommand(c *cli, args []string) error {
+	if len(args) > 0 {
+		return usagef("serve takes no arguments")
+	}
+	return serve(config.Load())
+}
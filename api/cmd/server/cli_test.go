@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestExitPrintsOneDocument(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       bool
+		err        error
+		code       int
+		wantStdout string
+		wantStderr bool
+	}{
+		{name: "success", err: nil, code: 0},
+		{name: "json error", json: true, err: errors.New("boom"), code: 1, wantStdout: `{"error":"boom"}` + "\n"},
+		{name: "json reported error", json: true, err: &reportedError{errors.New("boom")}, code: 1},
+		{name: "json usage error", json: true, err: usagef("bad"), code: 2, wantStdout: `{"error":"bad"}` + "\n"},
+		{name: "text reported error", err: &reportedError{errors.New("boom")}, code: 1, wantStderr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			c := &cli{json: tt.json, stdout: &stdout, stderr: &stderr}
+
+			if code := c.exit(tt.err); code != tt.code {
+				t.Errorf("exit code = %d, want %d", code, tt.code)
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if (stderr.Len() > 0) != tt.wantStderr {
+				t.Errorf("stderr = %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestResultWithReportedErrorIsValidJSON(t *testing.T) {
+	var stdout bytes.Buffer
+	c := &cli{json: true, stdout: &stdout}
+
+	err := &reportedError{errors.New("1 of 2 sessions failed to rescore")}
+	c.result(map[string]interface{}{"results": []string{"a", "b"}, "error": err.Error()}, "")
+	c.exit(err)
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not one JSON document: %v\n%s", err, stdout.String())
+	}
+	if doc["error"] == nil || doc["results"] == nil {
+		t.Errorf("document lacks results or error: %v", doc)
+	}
+}
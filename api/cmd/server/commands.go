@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"speaktrainer-api/internal/config"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/services"
+)
+
+func seedCommand(c *cli, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	file := fs.String("file", "", "prompts file (.json or .txt); defaults to the built-in prompts")
+	if rest, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return usagef("seed takes no positional arguments")
+	}
+
+	texts := services.DefaultPrompts
+	if *file != "" {
+		var err error
+		if texts, err = readPromptFile(*file); err != nil {
+			return err
+		}
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		return err
+	}
+
+	created, err := a.prompts.SeedPromptsFrom(texts)
+	if err != nil {
+		return err
+	}
+
+	c.result(map[string]int{"created": created}, "Seeded %d prompts\n", created)
+	return nil
+}
+
+func promptsCommand(c *cli, args []string) error {
+	if len(args) == 0 {
+		return usagef("prompts needs a subcommand: import or export")
+	}
+
+	switch args[0] {
+	case "import":
+		fs := flag.NewFlagSet("prompts import", flag.ContinueOnError)
+		org := fs.String("org", "", "organisation ID to import private prompts into; defaults to the global library")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) != 1 {
+			return usagef("prompts import needs exactly one file")
+		}
+
+		texts, err := readPromptFile(rest[0])
+		if err != nil {
+			return err
+		}
+
+		a, err := loadApp()
+		if err != nil {
+			return err
+		}
+
+		tenant, err := a.tenant(*org)
+		if err != nil {
+			return err
+		}
+
+		created, err := a.prompts.ImportPrompts(tenant, texts)
+		if err != nil {
+			return err
+		}
+
+		skipped := len(texts) - created
+		c.result(map[string]int{"created": created, "skipped": skipped},
+			"Imported %d prompts (%d skipped)\n", created, skipped)
+		return nil
+
+	case "export":
+		fs := flag.NewFlagSet("prompts export", flag.ContinueOnError)
+		file := fs.String("file", "", "write to this file instead of stdout")
+		org := fs.String("org", "", "organisation ID whose prompts to include; defaults to the global library only")
+		if rest, err := parseArgs(fs, args[1:]); err != nil {
+			return err
+		} else if len(rest) > 0 {
+			return usagef("prompts export takes no positional arguments")
+		}
+
+		a, err := loadApp()
+		if err != nil {
+			return err
+		}
+
+		tenant, err := a.tenant(*org)
+		if err != nil {
+			return err
+		}
+
+		prompts, err := a.prompts.GetAllPrompts(tenant)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(prompts, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if *file == "" {
+			fmt.Fprintln(c.stdout, string(data))
+			return nil
+		}
+
+		if err := os.WriteFile(*file, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", *file, err)
+		}
+		c.result(map[string]interface{}{"exported": len(prompts), "file": *file},
+			"Exported %d prompts to %s\n", len(prompts), *file)
+		return nil
+	}
+
+	return usagef("unknown prompts subcommand %q", args[0])
+}
+
+// readPromptFile reads prompt texts from JSON (an array of strings or of
+// objects with a "text" field, as written by prompts export) or from plain
+// text with one prompt per line
+func readPromptFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		var texts []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				texts = append(texts, line)
+			}
+		}
+		return texts, nil
+	}
+
+	var texts []string
+	if err := json.Unmarshal(data, &texts); err == nil {
+		return texts, nil
+	}
+
+	var prompts []struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &prompts); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: expected an array of strings or prompts", path)
+	}
+	for _, p := range prompts {
+		texts = append(texts, p.Text)
+	}
+	return texts, nil
+}
+
+func usersCommand(c *cli, args []string) error {
+	if len(args) == 0 {
+		return usagef("users needs a subcommand: create, promote or key")
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+		email := fs.String("email", "", "email address (required)")
+		name := fs.String("name", "", "display name (required)")
+		role := fs.String("role", models.RoleLearner, "learner, teacher or admin")
+		if rest, err := parseArgs(fs, args[1:]); err != nil {
+			return err
+		} else if len(rest) > 0 || *email == "" || *name == "" {
+			return usagef("users create needs -email and -name")
+		}
+		if !services.IsValidRole(*role) {
+			return usagef("invalid role %q", *role)
+		}
+
+		a, err := loadApp()
+		if err != nil {
+			return err
+		}
+
+		user, err := a.users.CreateUser(*email, *name, *role)
+		if err != nil {
+			return err
+		}
+
+		c.result(user, "Created %s user %s (%s)\n", user.Role, user.ID, user.Email)
+		return nil
+
+	case "promote":
+		fs := flag.NewFlagSet("users promote", flag.ContinueOnError)
+		role := fs.String("role", models.RoleTeacher, "learner, teacher or admin")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) != 1 {
+			return usagef("users promote needs exactly one user ID or email")
+		}
+		if !services.IsValidRole(*role) {
+			return usagef("invalid role %q", *role)
+		}
+
+		a, err := loadApp()
+		if err != nil {
+			return err
+		}
+
+		user, err := a.users.SetRole(rest[0], *role)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("user %q not found", rest[0])
+		}
+
+		c.result(user, "User %s is now %s\n", user.Email, user.Role)
+		return nil
+
+	case "key":
+		fs := flag.NewFlagSet("users key", flag.ContinueOnError)
+		name := fs.String("name", "cli", "what the key is for")
+		scopes := fs.String("scopes", strings.Join(models.AllScopes, ","), "comma-separated scopes")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) != 1 {
+			return usagef("users key needs exactly one user ID")
+		}
+
+		a, err := loadApp()
+		if err != nil {
+			return err
+		}
+
+		key, secret, err := a.apiKeys.CreateAPIKey(services.CreateAPIKeyRequest{
+			Name:      *name,
+			Scopes:    strings.Split(*scopes, ","),
+			UserID:    &rest[0],
+			CreatedBy: "cli",
+		})
+		if err != nil {
+			return err
+		}
+
+		c.result(map[string]interface{}{"api_key": key, "key": secret}, "Issued API key %s for user %s: %s\n", key.ID, rest[0], secret)
+		return nil
+	}
+
+	return usagef("unknown users subcommand %q", args[0])
+}
+
+type rescoreResult struct {
+	SessionID string `json:"session_id"`
+	Score     int    `json:"score,omitempty"`
+	MLModel   string `json:"ml_model,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func sessionsCommand(c *cli, args []string) error {
+	if len(args) == 0 || args[0] != "rescore" {
+		return usagef("sessions needs a subcommand: rescore")
+	}
+
+	fs := flag.NewFlagSet("sessions rescore", flag.ContinueOnError)
+	userID := fs.String("user", "", "only sessions of this user")
+	since := fs.String("since", "", "only sessions created on or after this date (YYYY-MM-DD)")
+	limit := fs.Int("limit", 0, "rescore at most this many sessions")
+	model := fs.String("model", "", "use this model class, fast or accurate")
+	if rest, err := parseArgs(fs, args[1:]); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return usagef("sessions rescore takes no positional arguments")
+	}
+	if *model != "" && *model != services.ModelFast && *model != services.ModelAccurate {
+		return usagef("invalid -model %q: use fast or accurate", *model)
+	}
+
+	filter := services.SessionFilter{UserID: *userID}
+	if *since != "" {
+		t, err := time.Parse(time.DateOnly, *since)
+		if err != nil {
+			return usagef("invalid -since date %q", *since)
+		}
+		filter.From = &t
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		return err
+	}
+
+	ids, err := a.sessions.RecordedSessionIDs(filter, *limit)
+	if err != nil {
+		return err
+	}
+
+	results := make([]rescoreResult, 0, len(ids))
+	failed := 0
+	for _, id := range ids {
+		result := rescoreResult{SessionID: id}
+		session, err := a.sessions.RescoreSession(context.Background(), id, *model)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+			failed++
+		case session != nil:
+			result.Score = session.Score
+			if session.MLModel != nil {
+				result.MLModel = *session.MLModel
+			}
+		}
+		results = append(results, result)
+
+		if !c.json {
+			if result.Error != "" {
+				fmt.Fprintf(c.stdout, "%s  failed: %s\n", id, result.Error)
+			} else {
+				fmt.Fprintf(c.stdout, "%s  score %d (%s)\n", id, result.Score, result.MLModel)
+			}
+		}
+	}
+
+	summary := map[string]interface{}{
+		"results":  results,
+		"rescored": len(ids) - failed,
+		"failed":   failed,
+	}
+	if failed > 0 {
+		err = &reportedError{fmt.Errorf("%d of %d sessions failed to rescore", failed, len(ids))}
+		summary["error"] = err.Error()
+	}
+
+	c.result(summary, "Rescored %d sessions, %d failed\n", len(ids)-failed, failed)
+	return err
+}
+
+func retentionCommand(c *cli, args []string) error {
+	if len(args) == 0 || args[0] != "sweep" {
+		return usagef("retention needs a subcommand: sweep")
+	}
+
+	fs := flag.NewFlagSet("retention sweep", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report what would be removed")
+	if rest, err := parseArgs(fs, args[1:]); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return usagef("retention sweep takes no positional arguments")
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		return err
+	}
+
+	var report *services.RetentionReport
+	if *dryRun {
+		report, err = a.retention.Report()
+	} else {
+		report, err = a.retention.Sweep(context.Background())
+	}
+	if err != nil {
+		return err
+	}
+
+	format := "Purged %d recordings and anonymised %d sessions\n"
+	if report.DryRun {
+		format = "Would purge %d recordings and anonymise %d sessions\n"
+	}
+	c.result(report, format, report.RecordingsPurged, report.SessionsAnonymised)
+	return nil
+}
+
+func mlCommand(c *cli, args []string) error {
+	if len(args) != 1 || args[0] != "ping" {
+		return usagef("ml needs a subcommand: ping")
+	}
+
+	// Only the ML backends are needed, so skip connecting to the database
+	cfg := config.Load()
+	backends, err := mlBackends(cfg)
+	if err != nil {
+		return err
+	}
+
+	results := make([]map[string]interface{}, 0, len(backends))
+	up := 0
+	for _, backend := range services.NewMLPool(backends).Backends() {
+		result := map[string]interface{}{
+			"name":  backend.Name,
+			"url":   backend.URL,
+			"model": backend.Model,
+		}
+
+		start := time.Now()
+		health, err := backend.Client.Health(context.Background())
+		latency := time.Since(start)
+		if err != nil {
+			result["status"] = "down"
+			result["error"] = err.Error()
+		} else {
+			result["status"] = health.Status
+			result["latency_ms"] = latency.Milliseconds()
+			up++
+		}
+		results = append(results, result)
+
+		if !c.json {
+			if err != nil {
+				fmt.Fprintf(c.stdout, "%s (%s) is down: %v\n", backend.Name, backend.URL, err)
+			} else {
+				fmt.Fprintf(c.stdout, "%s (%s) is %s (%v)\n", backend.Name, backend.URL, health.Status, latency.Round(time.Millisecond))
+			}
+		}
+	}
+
+	summary := map[string]interface{}{
+		"backends": results,
+		"up":       up,
+	}
+	if up == 0 {
+		err = &reportedError{errors.New("no ML backend is up")}
+		summary["error"] = err.Error()
+	}
+
+	c.result(summary, "%d of %d ML backends are up\n", up, len(backends))
+	return err
+}
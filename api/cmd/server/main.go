@@ -1,59 +1,186 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"speaktrainer-api/internal/config"
 	"speaktrainer-api/internal/database"
+	"speaktrainer-api/internal/docs"
 	"speaktrainer-api/internal/handlers"
-	"speaktrainer-api/internal/services"
+	"speaktrainer-api/internal/logging"
+	"speaktrainer-api/internal/metrics"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/ratelimit"
+	"speaktrainer-api/internal/tracing"
+	"speaktrainer-api/internal/version"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment")
-	}
+	envErr := godotenv.Load()
 
-	// Load configuration
 	cfg := config.Load()
+	logging.Setup(cfg.LogLevel, cfg.LogFormat)
+	if envErr != nil {
+		slog.Info("no .env file found, using system environment")
+	}
+
+	os.Exit(run(os.Args[1:]))
+}
+
+// serve runs the HTTP API until it fails or is asked to stop by SIGINT or
+// SIGTERM, in which case it drains in-flight work before returning
+func serve(cfg *config.Config) error {
+	// Anyone can send X-User-ID, so it may only name callers in development
+	if cfg.TrustUserIDHeader && cfg.Environment == "production" {
+		return fmt.Errorf("TRUST_USER_ID_HEADER must not be set in production")
+	}
 
-	// Initialize database
-	db, err := database.Connect(cfg.DatabaseURL)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp(cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return err
 	}
 
-	// Auto-migrate database tables
-	if err := database.Migrate(db); err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	// Apply pending schema migrations, unless deploys run `migrate up` themselves
+	if cfg.MigrateOnStart {
+		if err := database.Migrate(a.db); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
 	}
 
-	// Initialize services
-	mlClient := services.NewMLClient(cfg.MLServiceURL)
-	promptService := services.NewPromptService(db)
-	sessionService := services.NewSessionService(db, mlClient)
+	// Export connection pool statistics on /metrics
+	if sqlDB, err := a.db.DB(); err == nil {
+		metrics.RegisterDB(sqlDB)
+	}
 
 	// Seed database with initial prompts
-	if err := promptService.SeedPrompts(); err != nil {
-		log.Printf("Warning: Failed to seed prompts: %v", err)
+	if err := a.prompts.SeedPrompts(); err != nil {
+		slog.Warn("failed to seed prompts", "error", err)
 	} else {
-		log.Println("Database seeded successfully")
+		slog.Info("database seeded")
+	}
+
+	// Background workers stop when workerCtx is cancelled during shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	startWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+
+	// Keep leaderboard aggregates fresh in the background
+	startWorker(func(ctx context.Context) { a.leaderboard.RunRefresher(ctx, cfg.LeaderboardRefreshInterval) })
+
+	// Purge expired recordings and anonymise expired analyses
+	startWorker(func(ctx context.Context) { a.retention.RunSweeper(ctx, cfg.RetentionSweepInterval) })
+
+	// Drain the webhook outbox
+	startWorker(func(ctx context.Context) { a.webhooks.RunDispatcher(ctx, cfg.WebhookPollInterval) })
+
+	// Forget idempotency keys past their window
+	startWorker(func(ctx context.Context) { a.idempotency.RunPruner(ctx, time.Hour) })
+
+	// Drop expired cached analyses
+	if a.analysisCache != nil {
+		startWorker(func(ctx context.Context) { a.analysisCache.RunPruner(ctx, time.Hour) })
+	}
+
+	// Hear about analyses running on other replicas
+	if a.progressRelay != nil {
+		startWorker(func(ctx context.Context) { a.progressRelay.Listen(ctx, a.progress) })
+	}
+
+	// Notice ML backends going down and coming back
+	startWorker(func(ctx context.Context) { a.ml.RunHealthChecks(ctx, cfg.MLHealthInterval, cfg.HealthCheckTimeout) })
+
+	// Rate limits, shared by all replicas when buckets are kept in PostgreSQL
+	policies, err := ratelimit.ParsePolicies(cfg.RateLimits)
+	if err != nil {
+		return err
+	}
+	var rateLimitStore ratelimit.Store
+	var pgStore *ratelimit.PostgresStore
+	switch cfg.RateLimitStore {
+	case "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		pgStore = ratelimit.NewPostgresStore(a.db)
+		rateLimitStore = pgStore
+	default:
+		return fmt.Errorf("unknown rate limit store %q: use memory or postgres", cfg.RateLimitStore)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitStore, policies)
+	if pgStore != nil {
+		// Drop buckets that have refilled so the table doesn't grow forever
+		startWorker(func(ctx context.Context) { pgStore.RunPruner(ctx, time.Hour, limiter.MaxWindow()) })
 	}
 
 	// Initialize handlers
-	promptHandler := handlers.NewPromptHandler(promptService)
-	sessionHandler := handlers.NewSessionHandler(sessionService)
-	healthHandler := handlers.NewHealthHandler()
+	h := &routeHandlers{
+		prompt:       handlers.NewPromptHandler(a.prompts),
+		session:      handlers.NewSessionHandler(a.sessions),
+		health:       handlers.NewHealthHandler(a.health),
+		leaderboard:  handlers.NewLeaderboardHandler(a.leaderboard, a.users),
+		group:        handlers.NewGroupHandler(a.groups, a.users),
+		feedback:     handlers.NewFeedbackHandler(a.feedback, a.sessions, a.users),
+		notification: handlers.NewNotificationHandler(a.notifications),
+		me:           handlers.NewMeHandler(a.privacy),
+		admin:        handlers.NewAdminHandler(a.retention, a.apiKeys),
+		organisation: handlers.NewOrganisationHandler(a.organisations),
+		webhook:      handlers.NewWebhookHandler(a.webhooks),
+		sessionStream: handlers.NewSessionStreamHandler(a.sessions, handlers.StreamOptions{
+			MaxBytes:        cfg.StreamMaxBytes,
+			InterimInterval: cfg.StreamInterimInterval,
+			IdleTimeout:     cfg.StreamIdleTimeout,
+			CheckOrigin: func(origin string) bool {
+				return isOriginAllowed(origin, getCORSOrigins(cfg.Environment))
+			},
+		}),
+		sessionEvents: handlers.NewSessionEventsHandler(a.sessions, a.progress),
+		sessionBatch: handlers.NewSessionBatchHandler(a.sessions, handlers.BatchOptions{
+			MaxItems:     cfg.BatchMaxItems,
+			MaxFileBytes: cfg.BatchMaxFileBytes,
+			Concurrency:  cfg.BatchConcurrency,
+		}),
+		authenticate:  handlers.Authenticate(a.apiKeys, cfg.TrustUserIDHeader),
+		resolveTenant: handlers.ResolveTenant(a.users, a.organisations),
+		// The largest body any route takes is a full batch upload
+		idempotency:    handlers.Idempotency(a.idempotency, cfg.IdempotencyWait, int64(cfg.BatchMaxItems)*int64(cfg.BatchMaxFileBytes)+1<<20),
+		requireTeacher: handlers.RequireRole(a.users, models.RoleTeacher, models.RoleAdmin),
+		requireAdmin:   handlers.RequireRole(a.users, models.RoleAdmin),
+		limiter:        limiter,
+	}
 
 	// Setup router
-	router := setupRouter(cfg, promptHandler, sessionHandler, healthHandler)
+	router, err := setupRouter(cfg, h)
+	if err != nil {
+		return err
+	}
 
 	// Start server
 	srv := &http.Server{
@@ -63,28 +190,133 @@ func main() {
 		WriteTimeout: time.Second * 15,
 		IdleTimeout:  time.Second * 60,
 	}
+	srv.RegisterOnShutdown(h.sessionEvents.Shutdown)
+	srv.RegisterOnShutdown(h.sessionStream.Shutdown)
+
+	var mlBackendNames []string
+	for _, backend := range a.ml.Backends() {
+		mlBackendNames = append(mlBackendNames, backend.Name+"="+backend.URL)
+	}
 
-	log.Printf("Starting server on port %s", cfg.Port)
-	log.Printf("Environment: %s", cfg.Environment)
-	log.Printf("ML Service URL: %s", cfg.MLServiceURL)
+	slog.Info("starting server",
+		"port", cfg.Port,
+		"version", version.Version,
+		"commit", version.Commit,
+		"environment", cfg.Environment,
+		"ml_backends", mlBackendNames,
+	)
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatal("Server failed to start:", err)
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-signalCtx.Done():
+	}
+
+	// A second signal kills the process straight away
+	stopSignals()
+	slog.Info("shutting down", "timeout", cfg.ShutdownTimeout.String())
+
+	// Fail readiness first so load balancers stop routing here, then give
+	// them time to notice before connections are refused
+	h.health.SetDraining()
+	time.Sleep(cfg.ShutdownDrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests, such as
+	// analyses the ML service is still working on, to complete
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("in-flight requests did not finish in time", "error", err)
+	}
+
+	// WebSocket streams are hijacked, so Shutdown doesn't wait for them;
+	// analyses they started still need the database
+	if err := h.sessionStream.Wait(shutdownCtx); err != nil {
+		slog.Warn("analysis streams did not finish in time", "error", err)
+	}
+
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("background workers did not finish in time")
+	}
+
+	if sqlDB, err := a.db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Warn("failed to close database pool", "error", err)
+		}
 	}
+
+	// Flush spans still buffered for export
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("failed to flush traces", "error", err)
+	}
+
+	slog.Info("server stopped")
+	return nil
+}
+
+// routeHandlers bundles everything setupRouter mounts
+type routeHandlers struct {
+	prompt        *handlers.PromptHandler
+	session       *handlers.SessionHandler
+	health        *handlers.HealthHandler
+	leaderboard   *handlers.LeaderboardHandler
+	group         *handlers.GroupHandler
+	feedback      *handlers.FeedbackHandler
+	notification  *handlers.NotificationHandler
+	me            *handlers.MeHandler
+	admin         *handlers.AdminHandler
+	organisation  *handlers.OrganisationHandler
+	webhook       *handlers.WebhookHandler
+	sessionStream *handlers.SessionStreamHandler
+	sessionEvents *handlers.SessionEventsHandler
+	sessionBatch  *handlers.SessionBatchHandler
+
+	authenticate   gin.HandlerFunc
+	idempotency    gin.HandlerFunc
+	resolveTenant  gin.HandlerFunc
+	requireTeacher gin.HandlerFunc
+	requireAdmin   gin.HandlerFunc
+	limiter        *ratelimit.Limiter
 }
 
-func setupRouter(
-	cfg *config.Config,
-	promptHandler *handlers.PromptHandler,
-	sessionHandler *handlers.SessionHandler,
-	healthHandler *handlers.HealthHandler,
-) *gin.Engine {
+func setupRouter(cfg *config.Config, h *routeHandlers) (*gin.Engine, error) {
 	// Set Gin mode based on environment
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.Default()
+	// Gin's default text logger is replaced by structured access logs that
+	// carry the request ID and the trace the request belongs to
+	router := gin.New()
+
+	// Only the configured proxies may set the client IP with
+	// X-Forwarded-For; anyone else could forge it to dodge rate limits
+	if err := router.SetTrustedProxies(trustedProxies(cfg.TrustedProxies)); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	router.Use(tracing.Middleware(), logging.AssignRequestID(), logging.AccessLog(), gin.Recovery())
+
+	// Handlers report failures with c.Error; this writes the error envelope
+	router.Use(handlers.ErrorHandler())
+	router.Use(metrics.Middleware())
 
 	// CORS middleware with environment-based origins
 	router.Use(func(c *gin.Context) {
@@ -96,8 +328,9 @@ func setupRouter(
 			c.Header("Access-Control-Allow-Origin", origin)
 		}
 		
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, "+handlers.UserIDHeader+", "+handlers.IdempotencyKeyHeader+", "+logging.RequestIDHeader+", traceparent, tracestate")
+		c.Header("Access-Control-Expose-Headers", logging.RequestIDHeader+", Deprecation, Link, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, "+handlers.IdempotentReplayedHeader)
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -109,33 +342,139 @@ func setupRouter(
 	})
 
 	// Health check
-	router.GET("/health", healthHandler.Health)
-	router.GET("/", healthHandler.Root)
+	router.GET("/health", h.health.Health)
+	router.GET("/health/live", h.health.Live)
+	router.GET("/health/ready", h.health.Ready)
 
-	// API routes
-	api := router.Group("/api")
-	{
-		// Prompts - Full CRUD
-		prompts := api.Group("/prompts")
-		{
-			prompts.GET("", promptHandler.GetAllPrompts)
-			prompts.POST("", promptHandler.CreatePrompt)
-			prompts.GET("/random", promptHandler.GetRandomPrompt)
-			prompts.GET("/:id", promptHandler.GetPrompt)
-			prompts.PUT("/:id", promptHandler.UpdatePrompt)
-			prompts.DELETE("/:id", promptHandler.DeletePrompt)
-		}
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/", h.health.Root)
 
-		// Sessions
-		sessions := api.Group("/sessions")
-		{
-			sessions.POST("/analyze", sessionHandler.AnalyzePronunciation)
-			sessions.GET("/:id", sessionHandler.GetSession)
-			sessions.GET("", sessionHandler.GetSessions)
+	// API documentation
+	router.GET("/openapi.json", docs.ServeSpec)
+	router.GET("/docs", docs.ServeUI)
+
+	// API routes, versioned. The unversioned /api prefix still serves the
+	// same routes for existing clients but is deprecated.
+	registerAPIRoutes(router.Group("/api/v1"), h)
+	registerAPIRoutes(router.Group("/api", deprecatedAlias("/api", "/api/v1")), h)
+
+	return router, nil
+}
+
+// trustedProxies splits a comma-separated list of proxy IPs or CIDRs
+func trustedProxies(spec string) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(spec, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
 		}
 	}
+	return proxies
+}
+
+// registerAPIRoutes mounts the application routes on api. Each route names
+// the API key scope it needs; requests without a key aren't scope checked.
+func registerAPIRoutes(api *gin.RouterGroup, h *routeHandlers) {
+	// Authenticate first so API keys get their own rate limit buckets. The
+	// tenant decides which organisation's data every route below sees.
+	// Retried POSTs with an Idempotency-Key replay the first response.
+	api.Use(h.authenticate, handlers.RateLimit(h.limiter, "default"), h.resolveTenant, h.idempotency)
+
+	scope := handlers.RequireScope
+
+	// Prompts - Full CRUD
+	prompts := api.Group("/prompts")
+	{
+		prompts.GET("", scope(models.ScopePromptsRead), h.prompt.GetAllPrompts)
+		prompts.POST("", scope(models.ScopePromptsWrite), h.prompt.CreatePrompt)
+		prompts.GET("/random", scope(models.ScopePromptsRead), h.prompt.GetRandomPrompt)
+		prompts.GET("/:id", scope(models.ScopePromptsRead), h.prompt.GetPrompt)
+		prompts.PUT("/:id", scope(models.ScopePromptsWrite), h.prompt.UpdatePrompt)
+		prompts.DELETE("/:id", scope(models.ScopePromptsWrite), h.prompt.DeletePrompt)
+	}
+
+	// Sessions
+	sessions := api.Group("/sessions")
+	{
+		sessions.POST("/analyze", scope(models.ScopeSessionsWrite), handlers.RateLimit(h.limiter, "analyze"), h.session.AnalyzePronunciation)
+		sessions.GET("/stream", scope(models.ScopeSessionsWrite), handlers.RateLimit(h.limiter, "analyze"), h.sessionStream.Stream)
+		sessions.POST("/batch", scope(models.ScopeSessionsWrite), h.requireTeacher, handlers.RateLimit(h.limiter, "analyze"), h.sessionBatch.AnalyzeBatch)
+		sessions.GET("/:id", scope(models.ScopeSessionsRead), h.session.GetSession)
+		sessions.GET("/:id/events", scope(models.ScopeSessionsRead), h.sessionEvents.Events)
+		sessions.PATCH("/:id", scope(models.ScopeSessionsWrite), h.session.UpdateSession)
+		sessions.DELETE("/:id", scope(models.ScopeSessionsWrite), h.session.DeleteSession)
+		sessions.GET("", scope(models.ScopeSessionsRead), h.session.GetSessions)
+
+		// Teacher feedback threads
+		sessions.POST("/:id/feedback", scope(models.ScopeFeedbackWrite), h.requireTeacher, h.feedback.CreateFeedback)
+		sessions.POST("/:id/feedback/:feedback_id/comments", scope(models.ScopeFeedbackWrite), h.feedback.CreateComment)
+	}
+
+	// Notifications for the calling user
+	notifications := api.Group("/notifications", scope(models.ScopeAccount))
+	{
+		notifications.GET("", h.notification.GetNotifications)
+		notifications.POST("/:id/read", h.notification.MarkRead)
+	}
 
-	return router
+	// The calling user's own account: data export and erasure
+	me := api.Group("/me", scope(models.ScopeAccount))
+	{
+		me.GET("/export", h.me.Export)
+		me.DELETE("", h.me.Erase)
+	}
+
+	// The caller's organisation and the settings that apply to them
+	api.GET("/organisation", scope(models.ScopeAccount), h.organisation.GetCurrentOrganisation)
+
+	// Leaderboards
+	api.GET("/leaderboards", scope(models.ScopeLeaderboardsRead), h.leaderboard.GetLeaderboard)
+	api.PUT("/users/:id/leaderboard-opt-out", scope(models.ScopeAccount), h.leaderboard.UpdateOptOut)
+
+	// Groups (e.g. classrooms) used to scope leaderboards and set
+	// assignments, run by teachers
+	groups := api.Group("/groups")
+	{
+		groups.POST("", scope(models.ScopeGroupsWrite), h.requireTeacher, h.group.CreateGroup)
+		groups.GET("/:id", scope(models.ScopeGroupsRead), h.group.GetGroup)
+		groups.POST("/:id/members", scope(models.ScopeGroupsWrite), h.requireTeacher, h.group.AddMember)
+		groups.DELETE("/:id/members/:user_id", scope(models.ScopeGroupsWrite), h.requireTeacher, h.group.RemoveMember)
+		groups.GET("/:id/assignments", scope(models.ScopeGroupsRead), h.group.ListAssignments)
+		groups.POST("/:id/assignments", scope(models.ScopeGroupsWrite), h.requireTeacher, h.group.CreateAssignment)
+		groups.GET("/:id/assignments/:assignment_id", scope(models.ScopeGroupsRead), h.requireTeacher, h.group.GetAssignment)
+		groups.DELETE("/:id/assignments/:assignment_id", scope(models.ScopeGroupsWrite), h.requireTeacher, h.group.DeleteAssignment)
+	}
+
+	// Admin-only operations
+	admin := api.Group("/admin", scope(models.ScopeAdmin), h.requireAdmin)
+	{
+		admin.GET("/retention/report", h.admin.RetentionReport)
+
+		admin.GET("/api-keys", h.admin.ListAPIKeys)
+		admin.POST("/api-keys", h.admin.CreateAPIKey)
+		admin.DELETE("/api-keys/:id", h.admin.RevokeAPIKey)
+
+		admin.GET("/organisations", h.organisation.ListOrganisations)
+		admin.POST("/organisations", h.organisation.CreateOrganisation)
+		admin.PATCH("/organisations/:id", h.organisation.UpdateOrganisation)
+		admin.PUT("/organisations/:id/users/:user_id", h.organisation.AssignUser)
+
+		admin.GET("/webhooks", h.webhook.ListWebhooks)
+		admin.POST("/webhooks", h.webhook.CreateWebhook)
+		admin.DELETE("/webhooks/:id", h.webhook.DeleteWebhook)
+		admin.GET("/webhooks/:id/deliveries", h.webhook.ListDeliveries)
+	}
+}
+
+// deprecatedAlias marks responses served under an old prefix as deprecated
+// and points clients at the same route under its successor
+func deprecatedAlias(prefix, successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, strings.TrimPrefix(c.Request.URL.Path, prefix)))
+		c.Next()
+	}
 }
 
 func getCORSOrigins(environment string) []string {
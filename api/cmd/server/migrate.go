@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+
+	"speaktrainer-api/internal/database"
+)
+
+// migrateCommand implements `migrate up|down [n]|status|create <name>`
+func migrateCommand(c *cli, args []string) error {
+	if len(args) == 0 {
+		return usagef("migrate needs a subcommand: up, down, status or create")
+	}
+
+	command, args := args[0], args[1:]
+	if command == "create" {
+		return migrateCreate(c, args)
+	}
+
+	steps := 1
+	switch command {
+	case "up", "status":
+		if len(args) > 0 {
+			return usagef("migrate %s takes no arguments", command)
+		}
+	case "down":
+		if len(args) > 1 {
+			return usagef("migrate down takes at most one argument")
+		}
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return usagef("invalid number of steps %q", args[0])
+			}
+			steps = n
+		}
+	default:
+		return usagef("unknown migrate subcommand %q", command)
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		return err
+	}
+
+	migrator, err := database.NewMigrator(a.db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		printMigrations(c, "applied", applied)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 && !c.json {
+			c.result(nil, "database is up to date\n")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		printMigrations(c, "reverted", reverted)
+		if err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if c.json {
+			c.result(statuses, "")
+			return nil
+		}
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			c.result(nil, "%04d_%-40s %s\n", s.Version, s.Name, appliedAt)
+		}
+	}
+
+	return nil
+}
+
+func printMigrations(c *cli, verb string, migrations []database.Migration) {
+	if c.json {
+		type entry struct {
+			Version int64  `json:"version"`
+			Name    string `json:"name"`
+		}
+		entries := make([]entry, 0, len(migrations))
+		for _, m := range migrations {
+			entries = append(entries, entry{Version: m.Version, Name: m.Name})
+		}
+		c.result(map[string]interface{}{verb: entries}, "")
+		return
+	}
+	for _, m := range migrations {
+		c.result(nil, "%-8s %04d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+func migrateCreate(c *cli, args []string) error {
+	fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+	dir := fs.String("dir", "internal/database/migrations", "migrations source directory")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usagef("migrate create needs exactly one name")
+	}
+
+	paths, err := database.CreateMigration(*dir, rest[0])
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		c.result(map[string][]string{"created": paths}, "")
+		return nil
+	}
+	for _, path := range paths {
+		c.result(nil, "created  %s\n", path)
+	}
+	return nil
+}
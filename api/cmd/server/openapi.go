@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/config"
+	"speaktrainer-api/internal/docs"
+)
+
+// openapiCommand checks the OpenAPI document against the router, so CI can
+// fail when a route is added without documenting it
+func openapiCommand(c *cli, args []string) error {
+	if len(args) == 0 {
+		return usagef("openapi needs a subcommand: check or print")
+	}
+
+	switch args[0] {
+	case "print":
+		if len(args) > 1 {
+			return usagef("openapi print takes no arguments")
+		}
+		fmt.Fprint(c.stdout, string(docs.Spec()))
+		return nil
+
+	case "check":
+		if len(args) > 1 {
+			return usagef("openapi check takes no arguments")
+		}
+
+		// Handlers are never called, so the router can be built without
+		// connecting to anything
+		gin.SetMode(gin.ReleaseMode)
+		router, err := setupRouter(config.Load(), &routeHandlers{})
+		if err != nil {
+			return err
+		}
+		missing, err := undocumentedRoutes(router)
+		if err != nil {
+			return err
+		}
+
+		result := map[string]interface{}{"missing": missing}
+		if len(missing) > 0 {
+			err = &reportedError{fmt.Errorf("openapi.json does not document %d routes", len(missing))}
+			result["error"] = err.Error()
+		}
+		c.result(result, "%d routes missing from openapi.json\n%s", len(missing), formatList(missing))
+		return err
+	}
+
+	return usagef("unknown openapi subcommand %q", args[0])
+}
+
+// undocumentedRoutes lists the router's routes that openapi.json lacks. The
+// deprecated /api alias mirrors /api/v1 and isn't documented twice.
+func undocumentedRoutes(router *gin.Engine) ([]string, error) {
+	var routes gin.RoutesInfo
+	for _, route := range router.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/") || strings.HasPrefix(route.Path, "/api/v1/") {
+			routes = append(routes, route)
+		}
+	}
+	return docs.MissingRoutes(routes)
+}
+
+func formatList(items []string) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString("  " + item + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/config"
+)
+
+// testRouter builds the server's router with handlers that are never called
+func testRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router, err := setupRouter(&config.Config{Environment: "test"}, &routeHandlers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	missing, err := undocumentedRoutes(testRouter(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, route := range missing {
+		t.Errorf("%s is missing from openapi.json", route)
+	}
+}
+
+func TestUndocumentedRouteIsReported(t *testing.T) {
+	router := testRouter(t)
+	router.Handle(http.MethodGet, "/api/v1/undocumented/:id", func(*gin.Context) {})
+
+	missing, err := undocumentedRoutes(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 1 || missing[0] != "GET /api/v1/undocumented/{id}" {
+		t.Errorf("missing = %v, want [GET /api/v1/undocumented/{id}]", missing)
+	}
+}
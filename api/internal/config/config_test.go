@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", time.Minute},
+		{"30s", 30 * time.Second},
+		{"0s", time.Minute},
+		{"0", time.Minute},
+		{"-5s", time.Minute},
+		{"soon", time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("TEST_INTERVAL", tt.value)
+			if got := getInterval("TEST_INTERVAL", time.Minute); got != tt.want {
+				t.Errorf("getInterval(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is how long a query may take before it is logged
+// as a warning
+const slowQueryThreshold = 200 * time.Millisecond
+
+// slogLogger sends GORM's logs through slog. SQL statements are only
+// logged at debug level; slow queries and failures are always logged.
+type slogLogger struct {
+	level logger.LogLevel
+}
+
+// newLogger derives GORM's log level from the default slog logger
+func newLogger() logger.Interface {
+	level := logger.Warn
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		level = logger.Info
+	}
+	return &slogLogger{level: level}
+}
+
+func (l *slogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &slogLogger{level: level}
+}
+
+func (l *slogLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "query failed", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds(), "error", err)
+	case elapsed > slowQueryThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case l.level >= logger.Info:
+		sql, rows := fc()
+		slog.DebugContext(ctx, "query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	}
+}
@@ -0,0 +1,313 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the key of the PostgreSQL advisory lock held while
+// migrating, so replicas booting together apply migrations one at a time
+const migrationLockID = 7_413_205_118
+
+var (
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// Migration is a pair of up/down SQL scripts compiled into the binary
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	embedded, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := loadMigrations(embedded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: sqlDB, migrations: migrations}, nil
+}
+
+// Migrate applies every pending migration
+func Migrate(db *gorm.DB) error {
+	slog.Info("running database migrations")
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(context.Background())
+	for _, m := range applied {
+		slog.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+	return err
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down scripts", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations in version order
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if err := apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and when it was applied, if at all
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := done[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock, after making sure the schema_migrations table exists
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			slog.Warn("failed to release migration lock", "error", err)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    bigint PRIMARY KEY,
+			name       text NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+// apply runs one direction of a migration and records it in
+// schema_migrations within a single transaction
+func apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	script, direction := migration.Down, "down"
+	if up {
+		script, direction = migration.Up, "up"
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s (%s) failed: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// CreateMigration writes an empty up/down pair into dir, numbered after
+// the highest existing version, and returns the paths written
+func CreateMigration(dir, name string) ([]string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !migrationNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q", name)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("migrations directory: %w", err)
+	}
+
+	existing, err := loadMigrations(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var next int64 = 1
+	if len(existing) > 0 {
+		next = existing[len(existing)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", next, name, direction))
+		if err := os.WriteFile(path, []byte("-- "+direction+" migration\n"), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,27 @@
+-- Tables as previously created by GORM AutoMigrate. IF NOT EXISTS lets
+-- databases that were bootstrapped by AutoMigrate adopt this history.
+CREATE TABLE IF NOT EXISTS prompts (
+    id         text PRIMARY KEY,
+    text       text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id            text PRIMARY KEY,
+    expected_text text NOT NULL,
+    user_id       text,
+    transcription text NOT NULL,
+    score         bigint NOT NULL,
+    analysis_data jsonb,
+    created_at    timestamptz,
+    updated_at    timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id         text PRIMARY KEY,
+    email      text NOT NULL CONSTRAINT uni_users_email UNIQUE,
+    name       text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
@@ -0,0 +1,4 @@
+DROP MATERIALIZED VIEW IF EXISTS leaderboard_stats;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+ALTER TABLE users DROP COLUMN IF EXISTS leaderboard_opt_out;
@@ -0,0 +1,44 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS leaderboard_opt_out boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS groups (
+    id         text PRIMARY KEY,
+    name       text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id   text NOT NULL CONSTRAINT fk_groups_members REFERENCES groups (id) ON DELETE CASCADE,
+    user_id    text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id);
+
+-- One row per user and period ("weekly" or "all_time"), refreshed in the
+-- background by services.LeaderboardService. Improvement compares the
+-- average of a user's latest 5 sessions in the period with their earliest 5.
+CREATE MATERIALIZED VIEW IF NOT EXISTS leaderboard_stats AS
+WITH scoped AS (
+    SELECT 'all_time'::text AS period, user_id, score, created_at
+    FROM sessions WHERE user_id IS NOT NULL
+    UNION ALL
+    SELECT 'weekly'::text AS period, user_id, score, created_at
+    FROM sessions WHERE user_id IS NOT NULL AND created_at >= date_trunc('week', now())
+), ranked AS (
+    SELECT period, user_id, score,
+        ROW_NUMBER() OVER (PARTITION BY period, user_id ORDER BY created_at ASC) AS first_rank,
+        ROW_NUMBER() OVER (PARTITION BY period, user_id ORDER BY created_at DESC) AS last_rank
+    FROM scoped
+)
+SELECT period, user_id,
+    COUNT(*) AS sessions_completed,
+    AVG(score)::float8 AS average_score,
+    (AVG(score) FILTER (WHERE last_rank <= 5) - AVG(score) FILTER (WHERE first_rank <= 5))::float8 AS improvement,
+    now() AS refreshed_at
+FROM ranked
+GROUP BY period, user_id;
+
+-- A unique index is required for REFRESH MATERIALIZED VIEW CONCURRENTLY
+CREATE UNIQUE INDEX IF NOT EXISTS idx_leaderboard_stats_period_user ON leaderboard_stats (period, user_id);
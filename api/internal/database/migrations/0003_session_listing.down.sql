@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_sessions_created_at_id;
+DROP INDEX IF EXISTS idx_sessions_prompt_id;
+DROP INDEX IF EXISTS idx_sessions_user_id;
+ALTER TABLE sessions DROP CONSTRAINT IF EXISTS fk_sessions_prompt;
+ALTER TABLE sessions DROP COLUMN IF EXISTS prompt_id;
@@ -0,0 +1,13 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS prompt_id text;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_sessions_prompt') THEN
+        ALTER TABLE sessions ADD CONSTRAINT fk_sessions_prompt
+            FOREIGN KEY (prompt_id) REFERENCES prompts (id) ON DELETE SET NULL;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_prompt_id ON sessions (prompt_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_created_at_id ON sessions (created_at, id);
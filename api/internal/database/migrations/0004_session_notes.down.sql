@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_favourite;
+ALTER TABLE sessions DROP COLUMN IF EXISTS favourite;
+ALTER TABLE sessions DROP COLUMN IF EXISTS notes;
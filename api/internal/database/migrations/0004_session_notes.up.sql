@@ -0,0 +1,4 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS notes text NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS favourite boolean NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_favourite ON sessions (favourite);
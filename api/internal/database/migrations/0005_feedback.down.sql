@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS feedback_comments;
+DROP TABLE IF EXISTS session_feedbacks;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
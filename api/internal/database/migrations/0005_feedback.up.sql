@@ -0,0 +1,43 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'learner';
+
+CREATE TABLE IF NOT EXISTS session_feedbacks (
+    id                     text PRIMARY KEY,
+    session_id             text NOT NULL CONSTRAINT fk_sessions_feedback REFERENCES sessions (id) ON DELETE CASCADE,
+    teacher_id             text NOT NULL,
+    rubric_intelligibility bigint NOT NULL,
+    rubric_stress          bigint NOT NULL,
+    rubric_intonation      bigint NOT NULL,
+    rubric_fluency         bigint NOT NULL,
+    summary                text,
+    created_at             timestamptz,
+    updated_at             timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_feedbacks_session_id ON session_feedbacks (session_id);
+CREATE INDEX IF NOT EXISTS idx_session_feedbacks_teacher_id ON session_feedbacks (teacher_id);
+
+CREATE TABLE IF NOT EXISTS feedback_comments (
+    id          text PRIMARY KEY,
+    feedback_id text NOT NULL CONSTRAINT fk_session_feedbacks_comments REFERENCES session_feedbacks (id) ON DELETE CASCADE,
+    author_id   text NOT NULL,
+    body        text NOT NULL,
+    start_ms    bigint,
+    end_ms      bigint,
+    word_index  bigint,
+    word        text,
+    created_at  timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_feedback_comments_feedback_id ON feedback_comments (feedback_id);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id         text PRIMARY KEY,
+    user_id    text NOT NULL,
+    type       text NOT NULL,
+    session_id text,
+    message    text NOT NULL,
+    read_at    timestamptz,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id);
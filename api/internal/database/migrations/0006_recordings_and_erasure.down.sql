@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS erasure_audits;
+ALTER TABLE sessions DROP COLUMN IF EXISTS audio_key;
@@ -0,0 +1,12 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS audio_key text;
+
+-- Intentionally has no user reference, see models.ErasureAudit
+CREATE TABLE IF NOT EXISTS erasure_audits (
+    id                    text PRIMARY KEY,
+    sessions_deleted      bigint,
+    recordings_deleted    bigint,
+    notifications_deleted bigint,
+    comments_deleted      bigint,
+    user_row_deleted      boolean,
+    created_at            timestamptz
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_sessions_anonymised_at;
+ALTER TABLE sessions DROP COLUMN IF EXISTS anonymised_at;
@@ -0,0 +1,3 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS anonymised_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_anonymised_at ON sessions (anonymised_at);
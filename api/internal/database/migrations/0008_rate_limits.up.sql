@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key        text PRIMARY KEY,
+    tokens     double precision NOT NULL,
+    allowed    boolean NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets (updated_at);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id              text PRIMARY KEY,
+    name            text NOT NULL,
+    prefix          text NOT NULL,
+    hash            text NOT NULL,
+    scopes          jsonb NOT NULL,
+    user_id         text CONSTRAINT fk_users_api_keys REFERENCES users (id) ON DELETE CASCADE,
+    organisation_id text,
+    rate_limits     text NOT NULL DEFAULT '',
+    created_by      text NOT NULL,
+    last_used_at    timestamptz,
+    expires_at      timestamptz,
+    revoked_at      timestamptz,
+    created_at      timestamptz,
+    CONSTRAINT chk_api_keys_owner CHECK ((user_id IS NULL) <> (organisation_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys (prefix);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_organisation_id ON api_keys (organisation_id);
@@ -0,0 +1,6 @@
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS fk_organisations_api_keys;
+ALTER TABLE sessions DROP COLUMN IF EXISTS organisation_id;
+ALTER TABLE prompts DROP COLUMN IF EXISTS organisation_id;
+ALTER TABLE users DROP COLUMN IF EXISTS organisation_id;
+
+DROP TABLE IF EXISTS organisations;
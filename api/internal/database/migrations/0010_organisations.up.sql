@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS organisations (
+    id         text PRIMARY KEY,
+    name       text NOT NULL,
+    settings   jsonb NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+-- Existing rows stay outside any organisation: prompts form the global
+-- library and users and sessions keep working as before
+ALTER TABLE users ADD COLUMN IF NOT EXISTS organisation_id text
+    CONSTRAINT fk_organisations_users REFERENCES organisations (id);
+ALTER TABLE prompts ADD COLUMN IF NOT EXISTS organisation_id text
+    CONSTRAINT fk_organisations_prompts REFERENCES organisations (id) ON DELETE CASCADE;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS organisation_id text
+    CONSTRAINT fk_organisations_sessions REFERENCES organisations (id);
+ALTER TABLE api_keys ADD CONSTRAINT fk_organisations_api_keys
+    FOREIGN KEY (organisation_id) REFERENCES organisations (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_users_organisation_id ON users (organisation_id);
+CREATE INDEX IF NOT EXISTS idx_prompts_organisation_id ON prompts (organisation_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_organisation_id ON sessions (organisation_id);
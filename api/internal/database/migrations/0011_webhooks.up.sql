@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id              text PRIMARY KEY,
+    organisation_id text NOT NULL CONSTRAINT fk_organisations_webhook_subscriptions REFERENCES organisations (id) ON DELETE CASCADE,
+    url             text NOT NULL,
+    secret          text NOT NULL,
+    events          jsonb NOT NULL,
+    created_by      text NOT NULL,
+    created_at      timestamptz,
+    updated_at      timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_organisation_id ON webhook_subscriptions (organisation_id);
+
+-- The outbox: rows are inserted with the change they announce and drained
+-- by the delivery worker, then kept as the delivery log
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              text PRIMARY KEY,
+    subscription_id text NOT NULL CONSTRAINT fk_webhook_subscriptions_deliveries REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id        text NOT NULL,
+    event           text NOT NULL,
+    payload         jsonb NOT NULL,
+    status          text NOT NULL DEFAULT 'pending',
+    attempts        bigint NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_attempt_at timestamptz,
+    response_status bigint,
+    last_error      text NOT NULL DEFAULT '',
+    delivered_at    timestamptz,
+    created_at      timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS cached;
+DROP TABLE IF EXISTS analysis_cache_entries;
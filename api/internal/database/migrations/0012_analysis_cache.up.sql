@@ -0,0 +1,11 @@
+-- Analyses by hash of audio, expected text and model version, reused when
+-- a learner resubmits the same recording
+CREATE TABLE IF NOT EXISTS analysis_cache_entries (
+    key        text PRIMARY KEY,
+    response   jsonb NOT NULL,
+    created_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_cache_entries_created_at ON analysis_cache_entries (created_at);
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS cached boolean NOT NULL DEFAULT false;
@@ -0,0 +1,17 @@
+-- Responses to requests made with an Idempotency-Key header, replayed to
+-- retries of the same request
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    owner                 text NOT NULL,
+    key                   text NOT NULL,
+    fingerprint           text NOT NULL,
+    status                text NOT NULL DEFAULT 'pending',
+    locked_until          timestamptz NOT NULL,
+    response_status       bigint NOT NULL DEFAULT 0,
+    response_content_type text NOT NULL DEFAULT '',
+    response_body         bytea,
+    expires_at            timestamptz NOT NULL,
+    created_at            timestamptz,
+    PRIMARY KEY (owner, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
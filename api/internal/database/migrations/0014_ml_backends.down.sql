@@ -0,0 +1,3 @@
+ALTER TABLE analysis_cache_entries DROP COLUMN IF EXISTS backend;
+ALTER TABLE sessions DROP COLUMN IF EXISTS ml_model;
+ALTER TABLE sessions DROP COLUMN IF EXISTS ml_backend;
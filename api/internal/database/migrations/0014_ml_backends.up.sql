@@ -0,0 +1,6 @@
+-- Which ML backend and model scored each session, now that there can be
+-- more than one
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ml_backend text NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ml_model text NOT NULL DEFAULT '';
+
+ALTER TABLE analysis_cache_entries ADD COLUMN IF NOT EXISTS backend text NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE analysis_cache_entries DROP COLUMN IF EXISTS user_id;
+ALTER TABLE analysis_cache_entries DROP COLUMN IF EXISTS session_id;
@@ -0,0 +1,10 @@
+-- The session and user each cached analysis was made for, so the entry is
+-- erased with them. Entries cached before this can't be attributed; being a
+-- cache, they are simply dropped.
+ALTER TABLE analysis_cache_entries ADD COLUMN IF NOT EXISTS session_id text;
+ALTER TABLE analysis_cache_entries ADD COLUMN IF NOT EXISTS user_id text;
+
+DELETE FROM analysis_cache_entries WHERE session_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_analysis_cache_entries_session_id ON analysis_cache_entries (session_id);
+CREATE INDEX IF NOT EXISTS idx_analysis_cache_entries_user_id ON analysis_cache_entries (user_id);
@@ -0,0 +1,7 @@
+UPDATE sessions SET ml_backend = '' WHERE ml_backend IS NULL;
+UPDATE sessions SET ml_model = '' WHERE ml_model IS NULL;
+
+ALTER TABLE sessions ALTER COLUMN ml_backend SET DEFAULT '';
+ALTER TABLE sessions ALTER COLUMN ml_backend SET NOT NULL;
+ALTER TABLE sessions ALTER COLUMN ml_model SET DEFAULT '';
+ALTER TABLE sessions ALTER COLUMN ml_model SET NOT NULL;
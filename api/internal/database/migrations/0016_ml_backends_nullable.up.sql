@@ -0,0 +1,9 @@
+-- Sessions scored before ML backends were recorded can't say which model
+-- scored them, so they are NULL, unknown, rather than ''
+ALTER TABLE sessions ALTER COLUMN ml_backend DROP NOT NULL;
+ALTER TABLE sessions ALTER COLUMN ml_backend DROP DEFAULT;
+ALTER TABLE sessions ALTER COLUMN ml_model DROP NOT NULL;
+ALTER TABLE sessions ALTER COLUMN ml_model DROP DEFAULT;
+
+UPDATE sessions SET ml_backend = NULL WHERE ml_backend = '';
+UPDATE sessions SET ml_model = NULL WHERE ml_model = '';
@@ -0,0 +1,16 @@
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS organisation_id text
+    CONSTRAINT fk_organisations_groups REFERENCES organisations (id) ON DELETE CASCADE;
+
+-- A group whose members all belong to one organisation moves into it;
+-- any other group stays outside every organisation
+UPDATE groups g SET organisation_id = m.organisation_id
+FROM (
+    SELECT gm.group_id, min(u.organisation_id) AS organisation_id
+    FROM group_members gm
+    JOIN users u ON u.id = gm.user_id
+    GROUP BY gm.group_id
+    HAVING count(DISTINCT u.organisation_id) = 1 AND count(*) = count(u.organisation_id)
+) m
+WHERE g.id = m.group_id;
+
+CREATE INDEX IF NOT EXISTS idx_groups_organisation_id ON groups (organisation_id);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS assignments (
+    id              text PRIMARY KEY,
+    organisation_id text CONSTRAINT fk_organisations_assignments REFERENCES organisations (id) ON DELETE CASCADE,
+    group_id        text NOT NULL CONSTRAINT fk_groups_assignments REFERENCES groups (id) ON DELETE CASCADE,
+    prompt_id       text NOT NULL CONSTRAINT fk_prompts_assignments REFERENCES prompts (id) ON DELETE CASCADE,
+    due_at          timestamptz,
+    created_by      text NOT NULL,
+    created_at      timestamptz,
+    updated_at      timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_assignments_organisation_id ON assignments (organisation_id);
+CREATE INDEX IF NOT EXISTS idx_assignments_group_id ON assignments (group_id);
+CREATE INDEX IF NOT EXISTS idx_assignments_prompt_id ON assignments (prompt_id);
+
+CREATE TABLE IF NOT EXISTS assignment_completions (
+    assignment_id text NOT NULL CONSTRAINT fk_assignments_completions REFERENCES assignments (id) ON DELETE CASCADE,
+    user_id       text NOT NULL,
+    session_id    text NOT NULL CONSTRAINT fk_sessions_assignment_completions REFERENCES sessions (id) ON DELETE CASCADE,
+    score         integer NOT NULL,
+    created_at    timestamptz,
+    PRIMARY KEY (assignment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_assignment_completions_user_id ON assignment_completions (user_id);
+CREATE INDEX IF NOT EXISTS idx_assignment_completions_session_id ON assignment_completions (session_id);
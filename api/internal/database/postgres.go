@@ -1,19 +1,17 @@
 package database
 
 import (
-	"log"
+	"log/slog"
 	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-	"speaktrainer-api/internal/models"
 )
 
 func Connect(databaseURL string) (*gorm.DB, error) {
-	// Configure GORM logger
+	// Log SQL only at debug level, through the structured logger
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: newLogger(),
 	}
 
 	// Retry connection logic
@@ -24,34 +22,27 @@ func Connect(databaseURL string) (*gorm.DB, error) {
 
 	for i := 0; i < maxRetries; i++ {
 		db, err = gorm.Open(postgres.Open(databaseURL), gormConfig)
+		if err == nil {
+			err = db.Use(tracingPlugin{})
+		}
 		if err == nil {
 			// Test the connection
 			sqlDB, err := db.DB()
 			if err == nil {
 				err = sqlDB.Ping()
 				if err == nil {
-					log.Println("Connected to PostgreSQL database")
+					slog.Info("connected to PostgreSQL database")
 					return db, nil
 				}
 			}
 		}
 
-		log.Printf("Database connection attempt %d/%d failed: %v", i+1, maxRetries, err)
+		slog.Warn("database connection attempt failed", "attempt", i+1, "max_attempts", maxRetries, "error", err)
 		if i < maxRetries-1 {
-			log.Printf("Retrying in %v...", retryDelay)
+			slog.Info("retrying database connection", "delay", retryDelay.String())
 			time.Sleep(retryDelay)
 		}
 	}
 
 	return nil, err
 }
-
-func Migrate(db *gorm.DB) error {
-	log.Println("Running database migrations...")
-	
-	return db.AutoMigrate(
-		&models.Prompt{},
-		&models.Session{},
-		&models.User{},
-	)
-}
\ No newline at end of file
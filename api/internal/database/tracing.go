@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"speaktrainer-api/internal/tracing"
+)
+
+const spanKey = "speaktrainer:span"
+
+// tracingPlugin records a span for every GORM statement run with a context
+// that already carries a span, i.e. queries made via db.WithContext(ctx)
+// while serving a traced request. Background queries are not traced.
+type tracingPlugin struct{}
+
+func (tracingPlugin) Name() string {
+	return "tracing"
+}
+
+func (p tracingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		name  string
+		start func(string, func(*gorm.DB)) error
+		end   func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+
+	for _, h := range hooks {
+		if err := h.start("tracing:before_"+h.name, startSpan(h.name)); err != nil {
+			return err
+		}
+		if err := h.end("tracing:after_"+h.name, endSpan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+
+		ctx, span := tracing.Tracer().Start(ctx, "db."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperationName(operation),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	if table := db.Statement.Table; table != "" {
+		span.SetAttributes(semconv.DBCollectionName(table))
+	}
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
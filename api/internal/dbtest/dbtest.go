@@ -0,0 +1,36 @@
+// Package dbtest provides a database for tests that needs no PostgreSQL
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewMock returns a DB whose queries are answered by the returned mock.
+// Expected queries are regular expressions and must come in order. Writes
+// aren't wrapped in transactions, so tests needn't expect them. The test
+// fails if an expected query never came.
+func NewMock(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
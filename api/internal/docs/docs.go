@@ -0,0 +1,84 @@
+// Package docs serves the OpenAPI document describing the HTTP API and an
+// interactive UI for it. openapi.json is maintained by hand alongside the
+// routes in setupRouter; MissingRoutes reports routes it doesn't cover.
+package docs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// swaggerUIVersion is the swagger-ui-dist release loaded by the docs page
+const swaggerUIVersion = "5.17.14"
+
+var uiPage = fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>SpeakTrainer API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`, swaggerUIVersion)
+
+// Spec returns the raw OpenAPI document
+func Spec() []byte {
+	return spec
+}
+
+func ServeSpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", spec)
+}
+
+func ServeUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(uiPage))
+}
+
+// MissingRoutes lists the registered routes, as "METHOD /path", that have no
+// operation in the OpenAPI document
+func MissingRoutes(routes gin.RoutesInfo) ([]string, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi.json: %w", err)
+	}
+
+	missing := []string{}
+	for _, route := range routes {
+		path := openAPIPath(route.Path)
+		if _, ok := doc.Paths[path][strings.ToLower(route.Method)]; !ok {
+			missing = append(missing, route.Method+" "+path)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing, nil
+}
+
+// openAPIPath turns Gin's /sessions/:id into OpenAPI's /sessions/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
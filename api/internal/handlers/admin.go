@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/services"
+)
+
+// AdminHandler serves operational endpoints restricted to admins
+type AdminHandler struct {
+	retentionService *services.RetentionService
+	apiKeyService    *services.APIKeyService
+}
+
+type CreateAPIKeyRequest struct {
+	Name           string     `json:"name" binding:"required"`
+	Scopes         []string   `json:"scopes" binding:"required,min=1"`
+	UserID         *string    `json:"user_id"`
+	OrganisationID *string    `json:"organisation_id"`
+	RateLimits     string     `json:"rate_limits"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+func NewAdminHandler(retentionService *services.RetentionService, apiKeyService *services.APIKeyService) *AdminHandler {
+	return &AdminHandler{retentionService: retentionService, apiKeyService: apiKeyService}
+}
+
+// RetentionReport is a dry run of the retention sweep
+func (h *AdminHandler) RetentionReport(c *gin.Context) {
+	report, err := h.retentionService.Report()
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateAPIKey issues a key. The plaintext key is only in this response.
+func (h *AdminHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	key, secret, err := h.apiKeyService.CreateAPIKey(services.CreateAPIKeyRequest{
+		Tenant:         currentTenant(c),
+		Name:           req.Name,
+		Scopes:         req.Scopes,
+		UserID:         req.UserID,
+		OrganisationID: req.OrganisationID,
+		RateLimits:     req.RateLimits,
+		ExpiresAt:      req.ExpiresAt,
+		CreatedBy:      currentUser(c).ID,
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": secret})
+}
+
+func (h *AdminHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListAPIKeys(currentTenant(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeAPIKey stops a key working straight away. Revoked keys stay
+// listed so their usage can still be audited.
+func (h *AdminHandler) RevokeAPIKey(c *gin.Context) {
+	key, err := h.apiKeyService.RevokeAPIKey(currentTenant(c), c.Param("id"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
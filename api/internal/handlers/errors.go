@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"speaktrainer-api/internal/logging"
+	"speaktrainer-api/internal/services"
+)
+
+// Error codes in the response envelope. Clients should branch on these
+// rather than on messages, which may change.
+const (
+	CodeInvalidRequest  = "invalid_request"
+	CodeUnauthenticated = "unauthenticated"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodeRateLimited     = "rate_limited"
+	CodeUnavailable     = "unavailable"
+	CodeInternal        = "internal_error"
+)
+
+// APIError is an error with the status, code and message a client sees
+type APIError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+var (
+	errUnauthenticated = &APIError{Status: http.StatusUnauthorized, Code: CodeUnauthenticated, Message: "Authentication required"}
+	errForbidden       = &APIError{Status: http.StatusForbidden, Code: CodeForbidden, Message: "Insufficient permissions"}
+	errAPIKeyRequired  = &APIError{Status: http.StatusUnauthorized, Code: CodeUnauthenticated, Message: "This needs an API key of a user with the required role"}
+	errInternal        = &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "Internal server error"}
+)
+
+func invalidRequest(message string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Message: message}
+}
+
+// Report validation failures by JSON field name rather than Go field name
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// FieldError describes one invalid field of a request body
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+// bindError turns a ShouldBindJSON failure into an invalid_request error,
+// listing the failed fields when the body parsed but didn't validate
+func bindError(err error) *APIError {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return invalidRequest("Request body is not valid JSON for this endpoint")
+	}
+
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		// Namespace is e.g. CreateFeedbackRequest.rubric.stress; drop the type
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		fields = append(fields, FieldError{Field: field, Rule: fe.Tag()})
+	}
+
+	apiErr := invalidRequest("Request body failed validation")
+	apiErr.Details = fields
+	return apiErr
+}
+
+// fail records err for ErrorHandler and stops the handler chain. Handlers
+// return right after calling it.
+func fail(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// toAPIError maps an error to what the client is told. Errors that aren't
+// APIErrors or service error kinds are internal, and their messages are
+// never sent.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, services.ErrNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: err.Error()}
+	case errors.Is(err, services.ErrInvalidInput):
+		return invalidRequest(err.Error())
+	case errors.Is(err, services.ErrConflict):
+		return &APIError{Status: http.StatusConflict, Code: CodeConflict, Message: err.Error()}
+	case errors.Is(err, services.ErrUnavailable):
+		// The cause may name internal hosts, so it is only logged
+		return &APIError{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: "A service this request needs is unavailable, retry later"}
+	default:
+		return errInternal
+	}
+}
+
+// ErrorHandler writes the error envelope for the last error a handler
+// recorded with fail:
+//
+//	{"error": {"code": "...", "message": "...", "details": ..., "request_id": "..."}}
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		apiErr := toAPIError(err)
+		ctx := c.Request.Context()
+		if apiErr.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(ctx, "request failed", "error", err)
+		}
+
+		c.JSON(apiErr.Status, gin.H{"error": errorBody{apiErr, logging.RequestID(ctx)}})
+	}
+}
+
+// errorBody is the content of the "error" field of the envelope
+type errorBody struct {
+	*APIError
+	RequestID string `json:"request_id,omitempty"`
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/services"
+)
+
+type FeedbackHandler struct {
+	feedbackService *services.FeedbackService
+	sessionService  *services.SessionService
+	userService     *services.UserService
+}
+
+type RubricRequest struct {
+	Intelligibility int `json:"intelligibility" binding:"required"`
+	Stress          int `json:"stress" binding:"required"`
+	Intonation      int `json:"intonation" binding:"required"`
+	Fluency         int `json:"fluency" binding:"required"`
+}
+
+type CommentRequest struct {
+	Body      string `json:"body" binding:"required"`
+	StartMs   *int   `json:"start_ms"`
+	EndMs     *int   `json:"end_ms"`
+	WordIndex *int   `json:"word_index"`
+}
+
+type CreateFeedbackRequest struct {
+	Rubric   RubricRequest    `json:"rubric" binding:"required"`
+	Summary  string           `json:"summary"`
+	Comments []CommentRequest `json:"comments" binding:"dive"`
+}
+
+func NewFeedbackHandler(
+	feedbackService *services.FeedbackService,
+	sessionService *services.SessionService,
+	userService *services.UserService,
+) *FeedbackHandler {
+	return &FeedbackHandler{
+		feedbackService: feedbackService,
+		sessionService:  sessionService,
+		userService:     userService,
+	}
+}
+
+// CreateFeedback is only reachable by teachers (see RequireRole)
+func (h *FeedbackHandler) CreateFeedback(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	var req CreateFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	// Teachers only review sessions of their own organisation
+	session, err := h.sessionService.GetSessionByID(currentTenant(c), sessionID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	if session == nil {
+		fail(c, services.ErrSessionNotFound)
+		return
+	}
+
+	comments := make([]services.CommentInput, 0, len(req.Comments))
+	for _, comment := range req.Comments {
+		comments = append(comments, toCommentInput(comment))
+	}
+
+	feedback, err := h.feedbackService.AddFeedback(services.CreateFeedbackRequest{
+		SessionID: sessionID,
+		TeacherID: currentUser(c).ID,
+		Rubric: models.Rubric{
+			Intelligibility: req.Rubric.Intelligibility,
+			Stress:          req.Rubric.Stress,
+			Intonation:      req.Rubric.Intonation,
+			Fluency:         req.Rubric.Fluency,
+		},
+		Summary:  req.Summary,
+		Comments: comments,
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if feedback == nil {
+		fail(c, services.ErrSessionNotFound)
+		return
+	}
+
+	c.JSON(http.StatusCreated, feedback)
+}
+
+// CreateComment adds to a feedback thread. Teachers and the learner who
+// owns the session may comment.
+func (h *FeedbackHandler) CreateComment(c *gin.Context) {
+	sessionID := c.Param("id")
+	feedbackID := c.Param("feedback_id")
+
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	userID := currentUserID(c)
+	if userID == "" {
+		fail(c, errUnauthenticated)
+		return
+	}
+
+	if err := h.checkCanComment(c, userID, sessionID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	comment, err := h.feedbackService.AddComment(sessionID, feedbackID, userID, toCommentInput(req))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if comment == nil {
+		fail(c, services.ErrFeedbackNotFound)
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+func (h *FeedbackHandler) checkCanComment(c *gin.Context, userID, sessionID string) error {
+	// Sessions of other organisations are out of reach, even for teachers
+	session, err := h.sessionService.GetSessionByID(currentTenant(c), sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return services.ErrSessionNotFound
+	}
+	if session.UserID != nil && *session.UserID == userID {
+		return nil
+	}
+
+	_, err = callerWithRole(c, h.userService, models.RoleTeacher, models.RoleAdmin)
+	return err
+}
+
+func toCommentInput(req CommentRequest) services.CommentInput {
+	return services.CommentInput{
+		Body:      req.Body,
+		StartMs:   req.StartMs,
+		EndMs:     req.EndMs,
+		WordIndex: req.WordIndex,
+	}
+}
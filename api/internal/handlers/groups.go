@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/services"
+)
+
+// GroupHandler serves groups. Routes that change them are for teachers and
+// admins; a group's members may also read it.
+type GroupHandler struct {
+	groupService *services.GroupService
+	userService  *services.UserService
+}
+
+type CreateGroupRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type AddGroupMemberRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+type CreateAssignmentRequest struct {
+	PromptID string     `json:"prompt_id" binding:"required"`
+	DueAt    *time.Time `json:"due_at"`
+}
+
+func NewGroupHandler(groupService *services.GroupService, userService *services.UserService) *GroupHandler {
+	return &GroupHandler{groupService: groupService, userService: userService}
+}
+
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	var req CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	group, err := h.groupService.CreateGroup(currentTenant(c), req.Name)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, group)
+}
+
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	group, err := h.groupService.GetGroupByID(currentTenant(c), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if group == nil {
+		fail(c, services.ErrGroupNotFound)
+		return
+	}
+
+	if err := h.checkCanRead(c, group); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// checkCanRead lets the group's members, teachers and admins read it; other
+// learners can't see who is in a group they aren't in
+func (h *GroupHandler) checkCanRead(c *gin.Context, group *models.Group) error {
+	if isMember(group, currentUserID(c)) {
+		return nil
+	}
+	_, err := callerWithRole(c, h.userService, models.RoleTeacher, models.RoleAdmin)
+	return err
+}
+
+func isMember(group *models.Group, userID string) bool {
+	for _, member := range group.Members {
+		if userID != "" && member.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *GroupHandler) AddMember(c *gin.Context) {
+	id := c.Param("id")
+
+	var req AddGroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	group, err := h.groupService.AddMember(currentTenant(c), id, req.UserID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if group == nil {
+		fail(c, services.ErrGroupNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+func (h *GroupHandler) RemoveMember(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.Param("user_id")
+
+	err := h.groupService.RemoveMember(currentTenant(c), id, userID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group member removed successfully"})
+}
+
+func (h *GroupHandler) CreateAssignment(c *gin.Context) {
+	var req CreateAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	assignment, err := h.groupService.CreateAssignment(services.CreateAssignmentRequest{
+		Tenant:    currentTenant(c),
+		GroupID:   c.Param("id"),
+		PromptID:  req.PromptID,
+		DueAt:     req.DueAt,
+		CreatedBy: currentUser(c).ID,
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, assignment)
+}
+
+// ListAssignments lists a group's assignments for its members, teachers and
+// admins
+func (h *GroupHandler) ListAssignments(c *gin.Context) {
+	group, err := h.groupService.GetGroupByID(currentTenant(c), c.Param("id"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if group == nil {
+		fail(c, services.ErrGroupNotFound)
+		return
+	}
+
+	if err := h.checkCanRead(c, group); err != nil {
+		fail(c, err)
+		return
+	}
+
+	assignments, err := h.groupService.ListAssignments(currentTenant(c), group.ID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignments": assignments})
+}
+
+// GetAssignment returns an assignment with the members who have completed
+// it
+func (h *GroupHandler) GetAssignment(c *gin.Context) {
+	assignment, err := h.groupService.GetAssignment(currentTenant(c), c.Param("id"), c.Param("assignment_id"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if assignment == nil {
+		fail(c, services.ErrAssignmentNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, assignment)
+}
+
+func (h *GroupHandler) DeleteAssignment(c *gin.Context) {
+	err := h.groupService.DeleteAssignment(currentTenant(c), c.Param("id"), c.Param("assignment_id"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment deleted successfully"})
+}
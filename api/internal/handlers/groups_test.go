@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/dbtest"
+	"speaktrainer-api/internal/services"
+)
+
+func TestGetGroupMembersOnly(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller string
+		// apiKey proves the caller with their API key rather than X-User-ID
+		apiKey bool
+		// role is the caller's, looked up when they aren't a member
+		role   string
+		status int
+	}{
+		{name: "member", caller: "learner-1", status: http.StatusOK},
+		{name: "anonymous", status: http.StatusUnauthorized},
+		{name: "learner outside the group", caller: "learner-2", apiKey: true, role: "learner", status: http.StatusForbidden},
+		{name: "teacher outside the group", caller: "teacher-1", apiKey: true, role: "teacher", status: http.StatusOK},
+		{name: "teacher named only by X-User-ID", caller: "teacher-1", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			mock.ExpectQuery(`FROM "groups" WHERE id = \$1 AND groups\.organisation_id IS NULL`).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("group-1", "Class 1"))
+			mock.ExpectQuery(`FROM "group_members"`).WillReturnRows(sqlmock.NewRows([]string{"group_id", "user_id"}).AddRow("group-1", "learner-1"))
+			if tt.role != "" {
+				mock.ExpectQuery(`FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(tt.caller, tt.role))
+			}
+			handler := NewGroupHandler(services.NewGroupService(db), services.NewUserService(db))
+
+			var setup []gin.HandlerFunc
+			if tt.apiKey {
+				setup = append(setup, withKeyOf(tt.caller))
+			}
+			router := newTestRouter(setup...)
+			router.GET("/groups/:id", handler.GetGroup)
+
+			req := httptest.NewRequest(http.MethodGet, "/groups/group-1", nil)
+			if tt.caller != "" && !tt.apiKey {
+				req.Header.Set(UserIDHeader, tt.caller)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
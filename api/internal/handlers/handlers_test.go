@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/models"
+)
+
+// newTestRouter returns a router that writes the error envelope and, as in
+// development, takes the caller from X-User-ID, with setup run before the
+// handler under test, e.g. to act as a caller
+func newTestRouter(setup ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Use(Authenticate(nil, true))
+	router.Use(setup...)
+	return router
+}
+
+// withKeyOf acts as a caller who authenticated with an API key userID owns
+func withKeyOf(userID string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(currentAPIKeyKey, &models.APIKey{ID: "key-of-" + userID, UserID: &userID})
+	}
+}
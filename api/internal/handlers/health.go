@@ -2,28 +2,77 @@ package handlers
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/services"
+	"speaktrainer-api/internal/version"
 )
 
-type HealthHandler struct{}
+type HealthHandler struct {
+	healthService *services.HealthService
+	draining      atomic.Bool
+}
 
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
 }
 
-func (h *HealthHandler) Health(c *gin.Context) {
+// SetDraining makes readiness checks fail while the server shuts down
+func (h *HealthHandler) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Live reports that the process is up, without checking dependencies
+func (h *HealthHandler) Live(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"service": "speaktrainer-api",
-		"version": "1.0.0",
+		"status":     "alive",
+		"service":    "speaktrainer-api",
+		"version":    version.Version,
+		"commit":     version.Commit,
+		"build_time": version.BuildTime,
+	})
+}
+
+// Ready reports whether the API can serve traffic: it is not draining and
+// PostgreSQL and the ML service both respond
+func (h *HealthHandler) Ready(c *gin.Context) {
+	if h.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "draining",
+			"service": "speaktrainer-api",
+			"version": version.Version,
+			"commit":  version.Commit,
+		})
+		return
+	}
+
+	report := h.healthService.Check(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status != services.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, gin.H{
+		"status":       report.Status,
+		"service":      "speaktrainer-api",
+		"version":      version.Version,
+		"commit":       version.Commit,
+		"dependencies": report.Dependencies,
+		"checked_at":   report.CheckedAt,
 	})
 }
 
+// Health is kept for existing probes and behaves like Ready
+func (h *HealthHandler) Health(c *gin.Context) {
+	h.Ready(c)
+}
+
 func (h *HealthHandler) Root(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "SpeakTrainer API",
-		"version": "1.0.0",
+		"version": version.Version,
 		"docs":    "/docs",
 	})
-}
\ No newline at end of file
+}
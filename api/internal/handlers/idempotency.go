@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/services"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencyPollInterval  = 250 * time.Millisecond
+	// maxMemoryBody is the largest body kept in memory while it is hashed;
+	// larger ones are spooled to a temporary file
+	maxMemoryBody = 1 << 20
+)
+
+// Idempotency makes POST requests with an Idempotency-Key header safe to
+// retry. The first response the caller gets for a key is stored and
+// replayed to later requests with it; reusing the key for a different
+// request, including a different body, gets 422. A retry that arrives
+// while the first request is still running waits up to wait for it to
+// finish, then gets 409. Failures aren't stored, so a retry after one runs
+// again.
+//
+// Keys belong to the caller, as told apart by callerIdentity: the API key,
+// else the trusted X-User-ID user, else the client IP. Callers sharing an
+// IP share its keys, which are random enough, e.g. UUIDs, not to collide.
+// Bodies over maxBody are rejected.
+func Idempotency(idempotencyService *services.IdempotencyService, wait time.Duration, maxBody int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			fail(c, invalidRequest("Idempotency-Key must be at most 255 characters"))
+			return
+		}
+		// The body is hashed as sent, so for multipart requests a retry
+		// must resend the same bytes, boundary included. Reading it all
+		// here may take a large upload past the server's read timeout.
+		if err := allowUpload(c, maxBody); err != nil {
+			fail(c, err)
+			return
+		}
+		bodyHash, body, err := hashBody(c.Request.Body, maxBody)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		defer body.Close()
+		c.Request.Body = body
+
+		fingerprint := c.Request.Method + " " + c.Request.URL.Path + " " + bodyHash
+		owner := callerIdentity(c)
+		ctx := c.Request.Context()
+
+		record, err := beginIdempotent(ctx, idempotencyService, owner, key, fingerprint, wait)
+		if errors.Is(err, services.ErrIdempotencyKeyReused) {
+			fail(c, &APIError{Status: http.StatusUnprocessableEntity, Code: CodeInvalidRequest, Message: err.Error()})
+			return
+		}
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		if record != nil {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.ResponseStatus, record.ResponseContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		// Keep the key while the request runs, however long that is
+		stopHolding := idempotencyService.Hold(ctx, owner, key)
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		stopHolding()
+
+		// Settle the key even if the client has gone; it is the one most
+		// likely to retry. Errors recorded with fail aren't written yet,
+		// ErrorHandler does that later, so they are never stored.
+		settleCtx := context.WithoutCancel(ctx)
+		if writer.Written() && writer.Status() < http.StatusInternalServerError {
+			err = idempotencyService.Complete(settleCtx, owner, key, writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+		} else {
+			err = idempotencyService.Release(settleCtx, owner, key)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "failed to settle idempotency key", "error", err)
+		}
+	}
+}
+
+// beginIdempotent claims the key, polling while another request holds it
+// for up to wait
+func beginIdempotent(ctx context.Context, idempotencyService *services.IdempotencyService, owner, key, fingerprint string, wait time.Duration) (*models.IdempotencyKey, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		record, err := idempotencyService.Begin(ctx, owner, key, fingerprint)
+		if !errors.Is(err, services.ErrIdempotencyKeyInProgress) || time.Now().After(deadline) {
+			return record, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// hashBody reads body to its end and returns its SHA-256 with a copy to
+// read in its place, kept in memory when small and in a temporary file
+// otherwise. Closing the copy removes the file.
+func hashBody(body io.ReadCloser, maxBody int64) (string, io.ReadCloser, error) {
+	defer body.Close()
+	hash := sha256.New()
+	limited := io.LimitReader(body, maxBody+1)
+
+	var buf bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(hash, &buf), io.LimitReader(limited, maxMemoryBody+1))
+	if err != nil {
+		return "", nil, invalidRequest("Failed to read the request body")
+	}
+	var copied io.ReadCloser = io.NopCloser(&buf)
+
+	if n > maxMemoryBody {
+		file, err := os.CreateTemp("", "idempotent-body-*")
+		if err != nil {
+			return "", nil, err
+		}
+		spooled := &spooledBody{file}
+		rest, err := buf.WriteTo(file)
+		if err == nil {
+			rest, err = io.Copy(io.MultiWriter(hash, file), limited)
+			n += rest
+		}
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			spooled.Close()
+			return "", nil, invalidRequest("Failed to read the request body")
+		}
+		copied = spooled
+	}
+
+	if n > maxBody {
+		copied.Close()
+		return "", nil, invalidRequest("Request body is too large")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), copied, nil
+}
+
+// spooledBody is a request body read back from a temporary file
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	b.File.Close()
+	return os.Remove(b.Name())
+}
+
+// capturingWriter keeps a copy of the response body as it is written
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. for the
+// deadlines of long uploads
+func (w *capturingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
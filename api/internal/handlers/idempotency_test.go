@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/dbtest"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/services"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		maxBody int64
+		wantErr bool
+	}{
+		{"empty", 0, 10 << 20, false},
+		{"in memory", 1000, 10 << 20, false},
+		{"spooled", maxMemoryBody + 1000, 10 << 20, false},
+		{"at the limit", maxMemoryBody + 1000, maxMemoryBody + 1000, false},
+		{"over the limit", maxMemoryBody + 1001, maxMemoryBody + 1000, true},
+		{"small limit", 100, 99, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte("speak"), tt.size/5+1)[:tt.size]
+
+			hash, body, err := hashBody(io.NopCloser(bytes.NewReader(data)), tt.maxBody)
+			if tt.wantErr {
+				if err == nil {
+					body.Close()
+					t.Fatal("hashBody accepted a body over the limit")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if hash != sha256Hex(data) {
+				t.Errorf("hash = %s, want %s", hash, sha256Hex(data))
+			}
+			copied, err := io.ReadAll(body)
+			if err != nil || !bytes.Equal(copied, data) {
+				t.Errorf("copy of the body differs (%d bytes, %v)", len(copied), err)
+			}
+
+			spooled, isFile := body.(*spooledBody)
+			if isFile != (tt.size > maxMemoryBody) {
+				t.Errorf("spooled to a file = %v for %d bytes", isFile, tt.size)
+			}
+			body.Close()
+			if isFile {
+				if _, err := os.Stat(spooled.Name()); !os.IsNotExist(err) {
+					t.Errorf("spooled body still exists after Close: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	const path = "/items"
+	body := `{"name":"first"}`
+	fingerprint := "POST " + path + " " + sha256Hex([]byte(body))
+
+	tests := []struct {
+		name   string
+		apiKey bool
+		stored *models.IdempotencyKey
+		status int
+		replay bool
+	}{
+		{
+			name:   "without an API key",
+			stored: &models.IdempotencyKey{Fingerprint: fingerprint, Status: models.IdempotencyCompleted, ResponseStatus: http.StatusCreated},
+			status: http.StatusCreated,
+			replay: true,
+		},
+		{
+			name:   "completed request is replayed",
+			apiKey: true,
+			stored: &models.IdempotencyKey{Fingerprint: fingerprint, Status: models.IdempotencyCompleted, ResponseStatus: http.StatusCreated},
+			status: http.StatusCreated,
+			replay: true,
+		},
+		{
+			name:   "key reused with another body",
+			apiKey: true,
+			stored: &models.IdempotencyKey{Fingerprint: "POST " + path + " " + sha256Hex([]byte(`{"name":"second"}`)), Status: models.IdempotencyCompleted},
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "key reused on another route",
+			apiKey: true,
+			stored: &models.IdempotencyKey{Fingerprint: "POST /other " + sha256Hex([]byte(body)), Status: models.IdempotencyCompleted},
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			if tt.stored != nil {
+				mock.ExpectQuery(`INSERT INTO idempotency_keys`).WillReturnRows(sqlmock.NewRows([]string{"owner"}))
+				// Callers without an API key are told apart by IP
+				owner := "ip:192.0.2.1"
+				if tt.apiKey {
+					owner = "key:key-1"
+				}
+				mock.ExpectQuery(`SELECT \* FROM "idempotency_keys" WHERE owner = \$1 AND key = \$2`).
+					WithArgs(owner, "retry-1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"owner", "key", "fingerprint", "status", "response_status", "response_content_type", "response_body"}).
+						AddRow(owner, "retry-1", tt.stored.Fingerprint, tt.stored.Status, tt.stored.ResponseStatus, "application/json", []byte(`{"id":"1"}`)))
+			}
+
+			router := newTestRouter(func(c *gin.Context) {
+				if tt.apiKey {
+					c.Set(currentAPIKeyKey, &models.APIKey{ID: "key-1"})
+				}
+			})
+			router.Use(Idempotency(services.NewIdempotencyService(db, time.Hour), 0, 1<<20))
+			router.POST(path, func(c *gin.Context) {
+				t.Error("the handler ran")
+			})
+
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set(IdempotencyKeyHeader, "retry-1")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if replayed := rec.Header().Get(IdempotentReplayedHeader) == "true"; replayed != tt.replay {
+				t.Errorf("replayed = %v, want %v", replayed, tt.replay)
+			}
+		})
+	}
+}
+
+func TestIdempotencyPassesBodyOn(t *testing.T) {
+	db, mock := dbtest.NewMock(t)
+	mock.ExpectQuery(`INSERT INTO idempotency_keys`).WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("key:key-1"))
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	router := newTestRouter(func(c *gin.Context) {
+		c.Set(currentAPIKeyKey, &models.APIKey{ID: "key-1"})
+	})
+	router.Use(Idempotency(services.NewIdempotencyService(db, time.Hour), 0, 1<<20))
+	router.POST("/items", func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusCreated, string(data))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("payload"))
+	req.Header.Set(IdempotencyKeyHeader, "retry-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != "payload" {
+		t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body, http.StatusCreated, "payload")
+	}
+}
+
+func TestIdempotencyKeepsConnectionControl(t *testing.T) {
+	db, mock := dbtest.NewMock(t)
+	mock.ExpectQuery(`INSERT INTO idempotency_keys`).WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("key:key-1"))
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	router := newTestRouter(func(c *gin.Context) {
+		c.Set(currentAPIKeyKey, &models.APIKey{ID: "key-1"})
+	})
+	router.Use(Idempotency(services.NewIdempotencyService(db, time.Hour), 0, 1<<20))
+	router.POST("/items", func(c *gin.Context) {
+		// Long uploads and batches move the connection's deadlines
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			t.Errorf("SetWriteDeadline: %v", err)
+		}
+		c.String(http.StatusCreated, "created")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/items", strings.NewReader("payload"))
+	req.Header.Set(IdempotencyKeyHeader, "retry-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+}
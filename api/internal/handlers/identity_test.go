@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/dbtest"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/services"
+)
+
+func TestAuthenticateUserIDHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		trust bool
+		want  string
+	}{
+		{name: "trusted in development", trust: true, want: "user-1"},
+		{name: "ignored otherwise", trust: false, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			var got string
+			router.GET("/", Authenticate(nil, tt.trust), func(c *gin.Context) {
+				got = currentUserID(c)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(UserIDHeader, "user-1")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("currentUserID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name string
+		// header names the caller with X-User-ID; keyOwner with an API key
+		header   string
+		keyOwner string
+		role     string
+		status   int
+	}{
+		{name: "anonymous", status: http.StatusUnauthorized},
+		{name: "teacher named only by X-User-ID", header: "teacher-1", status: http.StatusUnauthorized},
+		{name: "learner's key", keyOwner: "learner-1", role: "learner", status: http.StatusForbidden},
+		{name: "teacher's key", keyOwner: "teacher-1", role: "teacher", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			if tt.role != "" {
+				mock.ExpectQuery(`FROM "users"`).WithArgs(tt.keyOwner, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(tt.keyOwner, tt.role))
+			}
+
+			var setup []gin.HandlerFunc
+			if tt.keyOwner != "" {
+				setup = append(setup, withKeyOf(tt.keyOwner))
+			}
+			router := newTestRouter(setup...)
+			router.GET("/", RequireRole(services.NewUserService(db), models.RoleTeacher), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(UserIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/services"
+)
+
+const maxLeaderboardLimit = 100
+
+type LeaderboardHandler struct {
+	leaderboardService *services.LeaderboardService
+	userService        *services.UserService
+}
+
+type UpdateOptOutRequest struct {
+	OptOut *bool `json:"opt_out" binding:"required"`
+}
+
+func NewLeaderboardHandler(leaderboardService *services.LeaderboardService, userService *services.UserService) *LeaderboardHandler {
+	return &LeaderboardHandler{leaderboardService: leaderboardService, userService: userService}
+}
+
+func (h *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
+	period := c.DefaultQuery("period", services.LeaderboardWeekly)
+	if !services.IsValidLeaderboardPeriod(period) {
+		fail(c, invalidRequest("Invalid period parameter"))
+		return
+	}
+
+	metric := c.DefaultQuery("metric", services.MetricAverageScore)
+	if !services.IsValidLeaderboardMetric(metric) {
+		fail(c, invalidRequest("Invalid metric parameter"))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > maxLeaderboardLimit {
+		fail(c, invalidRequest("Invalid limit parameter"))
+		return
+	}
+
+	groupID := c.Query("group_id")
+
+	entries, err := h.leaderboardService.GetLeaderboard(services.LeaderboardQuery{
+		Tenant:  currentTenant(c),
+		Period:  period,
+		Metric:  metric,
+		GroupID: groupID,
+		Limit:   limit,
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period":   period,
+		"metric":   metric,
+		"group_id": groupID,
+		"entries":  entries,
+	})
+}
+
+// UpdateOptOut changes whether a user appears on leaderboards. Users may
+// change their own; teachers and admins anyone's in their organisation.
+func (h *LeaderboardHandler) UpdateOptOut(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req UpdateOptOutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	if callerID := currentUserID(c); callerID == "" || callerID != userID {
+		if _, err := callerWithRole(c, h.userService, models.RoleTeacher, models.RoleAdmin); err != nil {
+			fail(c, err)
+			return
+		}
+	}
+
+	user, err := h.leaderboardService.SetOptOut(currentTenant(c), userID, *req.OptOut)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if user == nil {
+		fail(c, services.ErrUserNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": user.ID,
+		"opt_out": user.LeaderboardOptOut,
+	})
+}
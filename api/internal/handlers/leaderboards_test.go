@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/dbtest"
+	"speaktrainer-api/internal/services"
+)
+
+func TestUpdateOptOutPermissions(t *testing.T) {
+	userRow := func(id, role string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "role"}).AddRow(id, role)
+	}
+	noRows := sqlmock.NewRows([]string{"id"})
+
+	tests := []struct {
+		name   string
+		caller string
+		// apiKey proves the caller with their API key rather than X-User-ID
+		apiKey bool
+		target string
+		expect func(mock sqlmock.Sqlmock)
+		status int
+	}{
+		{
+			name:   "anonymous",
+			target: "learner-2",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "learner changing someone else",
+			caller: "learner-1",
+			apiKey: true,
+			target: "learner-2",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "users"`).WithArgs("learner-1", 1).WillReturnRows(userRow("learner-1", "learner"))
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "unknown caller",
+			caller: "ghost",
+			apiKey: true,
+			target: "learner-2",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "users"`).WillReturnRows(noRows)
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "teacher named only by X-User-ID",
+			caller: "teacher-1",
+			target: "learner-2",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "teacher changing a user of another organisation",
+			caller: "teacher-1",
+			apiKey: true,
+			target: "elsewhere",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "users"`).WillReturnRows(userRow("teacher-1", "teacher"))
+				mock.ExpectQuery(`FROM "users"`).WithArgs("elsewhere", 1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "learner changing their own",
+			caller: "learner-1",
+			target: "learner-1",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM "users"`).WithArgs("learner-1", 1).WillReturnRows(userRow("learner-1", "learner"))
+				mock.ExpectExec(`UPDATE "users"`).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			handler := NewLeaderboardHandler(services.NewLeaderboardService(db), services.NewUserService(db))
+
+			var setup []gin.HandlerFunc
+			if tt.apiKey {
+				setup = append(setup, withKeyOf(tt.caller))
+			}
+			router := newTestRouter(setup...)
+			router.PUT("/users/:id/leaderboard-opt-out", handler.UpdateOptOut)
+
+			req := httptest.NewRequest(http.MethodPut, "/users/"+tt.target+"/leaderboard-opt-out", strings.NewReader(`{"opt_out": true}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.caller != "" && !tt.apiKey {
+				req.Header.Set(UserIDHeader, tt.caller)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
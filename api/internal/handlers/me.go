@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/services"
+)
+
+// MeHandler serves requests about the calling user's own account
+type MeHandler struct {
+	privacyService *services.PrivacyService
+}
+
+func NewMeHandler(privacyService *services.PrivacyService) *MeHandler {
+	return &MeHandler{privacyService: privacyService}
+}
+
+func (h *MeHandler) Export(c *gin.Context) {
+	userID := currentUserID(c)
+	if userID == "" {
+		fail(c, errUnauthenticated)
+		return
+	}
+
+	export, err := h.privacyService.LoadUserExport(userID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="speaktrainer-export.zip"`)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure here can only be logged
+	if err := h.privacyService.WriteArchive(export, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "export failed", "user_id", userID, "error", err)
+	}
+}
+
+func (h *MeHandler) Erase(c *gin.Context) {
+	userID := currentUserID(c)
+	if userID == "" {
+		fail(c, errUnauthenticated)
+		return
+	}
+
+	audit, err := h.privacyService.EraseUser(userID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Account erased successfully",
+		"erasure": audit,
+	})
+}
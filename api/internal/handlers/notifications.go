@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/services"
+)
+
+type NotificationHandler struct {
+	notificationService *services.NotificationService
+}
+
+func NewNotificationHandler(notificationService *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	userID := currentUserID(c)
+	if userID == "" {
+		fail(c, errUnauthenticated)
+		return
+	}
+
+	notifications, err := h.notificationService.ListNotifications(userID, c.Query("unread") == "true")
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications})
+}
+
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := currentUserID(c)
+	if userID == "" {
+		fail(c, errUnauthenticated)
+		return
+	}
+
+	err := h.notificationService.MarkRead(c.Param("id"), userID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/services"
+)
+
+type OrganisationHandler struct {
+	organisationService *services.OrganisationService
+}
+
+type CreateOrganisationRequest struct {
+	Name     string                       `json:"name" binding:"required"`
+	Settings *models.OrganisationSettings `json:"settings"`
+}
+
+type UpdateOrganisationRequest struct {
+	Name     *string                      `json:"name"`
+	Settings *models.OrganisationSettings `json:"settings"`
+}
+
+func NewOrganisationHandler(organisationService *services.OrganisationService) *OrganisationHandler {
+	return &OrganisationHandler{organisationService: organisationService}
+}
+
+// errPlatformAdminOnly rejects admins of a single organisation from
+// operations that span organisations
+var errPlatformAdminOnly = &APIError{
+	Status:  http.StatusForbidden,
+	Code:    CodeForbidden,
+	Message: "Only admins outside any organisation can do this",
+}
+
+// GetCurrentOrganisation returns the caller's organisation, or null when
+// they belong to none, with the settings that apply to them either way
+func (h *OrganisationHandler) GetCurrentOrganisation(c *gin.Context) {
+	tenant := currentTenant(c)
+
+	var org *models.Organisation
+	if tenant.OrganisationID != "" {
+		var err error
+		if org, err = h.organisationService.GetOrganisationByID(tenant.OrganisationID); err != nil {
+			fail(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organisation": org,
+		"settings":     tenant.Settings,
+	})
+}
+
+func (h *OrganisationHandler) ListOrganisations(c *gin.Context) {
+	orgs, err := h.organisationService.ListOrganisations(currentTenant(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organisations": orgs})
+}
+
+func (h *OrganisationHandler) CreateOrganisation(c *gin.Context) {
+	if currentTenant(c).OrganisationID != "" {
+		fail(c, errPlatformAdminOnly)
+		return
+	}
+
+	var req CreateOrganisationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	org, err := h.organisationService.CreateOrganisation(req.Name, req.Settings)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// UpdateOrganisation changes an organisation's name or settings. Admins
+// inside an organisation may only change their own.
+func (h *OrganisationHandler) UpdateOrganisation(c *gin.Context) {
+	var req UpdateOrganisationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	org, err := h.organisationService.UpdateOrganisation(currentTenant(c), c.Param("id"), services.UpdateOrganisationRequest{
+		Name:     req.Name,
+		Settings: req.Settings,
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// AssignUser moves a user, with their sessions, into an organisation
+func (h *OrganisationHandler) AssignUser(c *gin.Context) {
+	if currentTenant(c).OrganisationID != "" {
+		fail(c, errPlatformAdminOnly)
+		return
+	}
+
+	user, err := h.organisationService.AssignUser(c.Param("id"), c.Param("user_id"))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
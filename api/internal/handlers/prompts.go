@@ -24,9 +24,9 @@ func NewPromptHandler(promptService *services.PromptService) *PromptHandler {
 }
 
 func (h *PromptHandler) GetAllPrompts(c *gin.Context) {
-	prompts, err := h.promptService.GetAllPrompts()
+	prompts, err := h.promptService.GetAllPrompts(currentTenant(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
@@ -34,9 +34,9 @@ func (h *PromptHandler) GetAllPrompts(c *gin.Context) {
 }
 
 func (h *PromptHandler) GetRandomPrompt(c *gin.Context) {
-	prompt, err := h.promptService.GetRandomPrompt()
+	prompt, err := h.promptService.GetRandomPrompt(currentTenant(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
@@ -49,14 +49,14 @@ func (h *PromptHandler) GetRandomPrompt(c *gin.Context) {
 func (h *PromptHandler) GetPrompt(c *gin.Context) {
 	id := c.Param("id")
 	
-	prompt, err := h.promptService.GetPromptByID(id)
+	prompt, err := h.promptService.GetPromptByID(currentTenant(c), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
 	if prompt == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		fail(c, services.ErrPromptNotFound)
 		return
 	}
 
@@ -66,13 +66,13 @@ func (h *PromptHandler) GetPrompt(c *gin.Context) {
 func (h *PromptHandler) CreatePrompt(c *gin.Context) {
 	var req CreatePromptRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(c, bindError(err))
 		return
 	}
 
-	prompt, err := h.promptService.CreatePrompt(req.Text)
+	prompt, err := h.promptService.CreatePrompt(currentTenant(c), req.Text)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
@@ -84,18 +84,18 @@ func (h *PromptHandler) UpdatePrompt(c *gin.Context) {
 	
 	var req UpdatePromptRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(c, bindError(err))
 		return
 	}
 
-	prompt, err := h.promptService.UpdatePrompt(id, req.Text)
+	prompt, err := h.promptService.UpdatePrompt(currentTenant(c), id, req.Text)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
 	if prompt == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		fail(c, services.ErrPromptNotFound)
 		return
 	}
 
@@ -105,13 +105,9 @@ func (h *PromptHandler) UpdatePrompt(c *gin.Context) {
 func (h *PromptHandler) DeletePrompt(c *gin.Context) {
 	id := c.Param("id")
 
-	err := h.promptService.DeletePrompt(id)
+	err := h.promptService.DeletePrompt(currentTenant(c), id)
 	if err != nil {
-		if err.Error() == "prompt not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
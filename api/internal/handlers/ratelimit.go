@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/ratelimit"
+)
+
+// RateLimit spends one token of the caller's bucket for policy per request
+// and rejects requests with 429 once it is empty. An API key's own limits
+// replace the configured ones for the policies they name. Policies that
+// are configured nowhere let everything through.
+func RateLimit(limiter *ratelimit.Limiter, policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, ok := limiter.Policy(policy)
+		if key := currentAPIKey(c); key != nil && key.RateLimits != "" {
+			// Validated when the key was created
+			if overrides, err := ratelimit.ParsePolicies(key.RateLimits); err == nil {
+				if override, found := overrides[policy]; found {
+					limit, ok = override, true
+				}
+			}
+		}
+		if !ok {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Take(c.Request.Context(), policy, callerIdentity(c), limit)
+		if err != nil {
+			// Fail open: an unavailable store shouldn't take the API down
+			slog.WarnContext(c.Request.Context(), "rate limiting skipped", "policy", policy, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.Reset.Seconds()))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", result.Limit.Burst, ceilSeconds(result.Limit.Window().Seconds())))
+
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter.Seconds()))
+			fail(c, &APIError{
+				Status:  http.StatusTooManyRequests,
+				Code:    CodeRateLimited,
+				Message: "Rate limit exceeded, retry later",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// callerIdentity is who a request comes from, e.g. whose rate limit bucket
+// it draws from: the API key when one authenticated the request, else the
+// user X-User-ID names where it is trusted, otherwise the client IP
+func callerIdentity(c *gin.Context) string {
+	if key := currentAPIKey(c); key != nil {
+		return "key:" + key.ID
+	}
+	if userID := currentUserID(c); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(s float64) string {
+	return strconv.Itoa(int(math.Ceil(s)))
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/models"
+)
+
+func TestCallerIdentity(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		apiKey  *models.APIKey
+		headers map[string]string
+		want    string
+	}{
+		{
+			name: "anonymous caller is bucketed by IP",
+			want: "ip:192.0.2.1",
+		},
+		{
+			name:    "trusted X-User-ID picks the bucket",
+			headers: map[string]string{UserIDHeader: "user-1"},
+			want:    "user:user-1",
+		},
+		{
+			name:    "API key picks the bucket",
+			apiKey:  &models.APIKey{ID: "key-1"},
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.9", UserIDHeader: "user-1"},
+			want:    "key:key-1",
+		},
+		{
+			name:    "X-Forwarded-For from an untrusted peer is ignored",
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.9"},
+			want:    "ip:192.0.2.1",
+		},
+		{
+			name:    "X-Forwarded-For from a trusted proxy is believed",
+			trusted: []string{"192.0.2.0/24"},
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.9"},
+			want:    "ip:203.0.113.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(func(c *gin.Context) {
+				if tt.apiKey != nil {
+					c.Set(currentAPIKeyKey, tt.apiKey)
+				}
+			})
+			if err := router.SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatal(err)
+			}
+			router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, callerIdentity(c)) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("callerIdentity = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/logging"
+	"speaktrainer-api/internal/metrics"
+	"speaktrainer-api/internal/services"
+)
+
+// Statuses of a batch item
+const (
+	batchSucceeded = "succeeded"
+	batchFailed    = "failed"
+)
+
+const (
+	// uploadRate is the slowest upload, in bytes a second, large bodies are
+	// given time for, e.g. a teacher's phone on a weak mobile connection
+	uploadRate = 256 << 10
+	// uploadGrace is added to every upload's time, as the server's read
+	// timeout would give
+	uploadGrace = 15 * time.Second
+)
+
+var errBatchTooLarge = &APIError{Status: http.StatusRequestEntityTooLarge, Code: CodeInvalidRequest, Message: "Batch upload is too large"}
+
+// BatchOptions bounds a batch analysis
+type BatchOptions struct {
+	MaxItems     int
+	MaxFileBytes int
+	// Concurrency is how many items of one batch are analysed at once
+	Concurrency int
+}
+
+type SessionBatchHandler struct {
+	sessionService *services.SessionService
+	options        BatchOptions
+}
+
+// BatchManifest maps the uploaded files to learners and texts. Top-level
+// expected_text, prompt_id and model apply to items that don't set their
+// own, e.g. when a whole class reads the same passage.
+type BatchManifest struct {
+	ExpectedText string              `json:"expected_text"`
+	PromptID     *string             `json:"prompt_id"`
+	Model        string              `json:"model"`
+	Items        []BatchManifestItem `json:"items"`
+}
+
+type BatchManifestItem struct {
+	// File is the name of an audio_files part, or a path inside the archive
+	File         string  `json:"file"`
+	UserID       *string `json:"user_id"`
+	ExpectedText string  `json:"expected_text"`
+	PromptID     *string `json:"prompt_id"`
+	SessionID    string  `json:"session_id"`
+	Model        string  `json:"model"`
+}
+
+type batchItemResult struct {
+	Index  int        `json:"index"`
+	File   string     `json:"file"`
+	UserID *string    `json:"user_id,omitempty"`
+	Status string     `json:"status"`
+	Result gin.H      `json:"result,omitempty"`
+	Error  *errorBody `json:"error,omitempty"`
+}
+
+func NewSessionBatchHandler(sessionService *services.SessionService, options BatchOptions) *SessionBatchHandler {
+	return &SessionBatchHandler{sessionService: sessionService, options: options}
+}
+
+// AnalyzeBatch scores many recordings at once, e.g. a whole class reading
+// a passage. Audio comes as audio_files parts, a ZIP in archive, or both,
+// and the manifest says whose each file is. Items fail on their own; the
+// response lists every item's result or error.
+func (h *SessionBatchHandler) AnalyzeBatch(c *gin.Context) {
+	// Every file at its largest, plus room for the manifest and framing
+	limit := int64(h.options.MaxItems)*int64(h.options.MaxFileBytes) + 1<<20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+
+	if err := allowUpload(c, limit); err != nil {
+		fail(c, err)
+		return
+	}
+
+	manifest, err := h.readManifest(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	files, closeFiles, err := h.collectFiles(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	defer closeFiles()
+
+	// Items that are incomplete fail here; the rest are analysed
+	tenant := currentTenant(c)
+	results := make([]batchItemResult, len(manifest.Items))
+	var items []services.BatchItem
+	var itemIndex []int
+	for i, entry := range manifest.Items {
+		results[i] = batchItemResult{Index: i, File: entry.File, UserID: entry.UserID}
+
+		item, err := h.batchItem(manifest, entry, files)
+		if err != nil {
+			results[i].fail(c, err)
+			continue
+		}
+		items = append(items, item)
+		itemIndex = append(itemIndex, i)
+	}
+
+	// A batch outlasts the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		fail(c, err)
+		return
+	}
+
+	outcomes, err := h.sessionService.AnalyzeBatch(c.Request.Context(), tenant, items, h.options.Concurrency)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	succeeded := 0
+	for j, outcome := range outcomes {
+		result := &results[itemIndex[j]]
+		if outcome.Err != nil {
+			result.fail(c, outcome.Err)
+			continue
+		}
+		result.Status = batchSucceeded
+		result.Result = analysisResponse(outcome.Result, tenant)
+		succeeded++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
+
+// readManifest takes the manifest from a form field or, as curl sends
+// -F manifest=@file, a file part
+func (h *SessionBatchHandler) readManifest(c *gin.Context) (*BatchManifest, error) {
+	if _, err := c.MultipartForm(); err != nil {
+		return nil, formError(err)
+	}
+
+	raw := c.PostForm("manifest")
+	if raw == "" {
+		if file, _, err := c.Request.FormFile("manifest"); err == nil {
+			defer file.Close()
+			data, err := io.ReadAll(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read manifest: %w", err)
+			}
+			raw = string(data)
+		}
+	}
+	if raw == "" {
+		return nil, invalidRequest("manifest is required")
+	}
+
+	var manifest BatchManifest
+	if err := json.Unmarshal([]byte(raw), &manifest); err != nil {
+		return nil, invalidRequest("manifest is not valid JSON")
+	}
+	if len(manifest.Items) == 0 {
+		return nil, invalidRequest("manifest has no items")
+	}
+	if len(manifest.Items) > h.options.MaxItems {
+		return nil, invalidRequest(fmt.Sprintf("A batch may have at most %d items", h.options.MaxItems))
+	}
+	return &manifest, nil
+}
+
+// allowUpload gives the request as long to send size bytes as uploadRate
+// takes, since the server's read timeout would cut a large upload off on a
+// slow connection. Writers without deadlines, e.g. in tests, are fine.
+func allowUpload(c *gin.Context, size int64) error {
+	deadline := time.Now().Add(uploadGrace + time.Duration(size/uploadRate)*time.Second)
+	err := http.NewResponseController(c.Writer).SetReadDeadline(deadline)
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+// formError is the error for a batch upload that couldn't be parsed
+func formError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errBatchTooLarge
+	}
+	return invalidRequest("Request must be multipart/form-data")
+}
+
+// audioFile opens one uploaded recording
+type audioFile func() (io.ReadCloser, error)
+
+// collectFiles indexes the uploaded audio by name. Nothing is read until
+// an item's turn comes, so unused archive entries cost nothing. closeFiles
+// closes the archives once the batch is done.
+func (h *SessionBatchHandler) collectFiles(c *gin.Context) (files map[string]audioFile, closeFiles func(), err error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, nil, formError(err)
+	}
+
+	var archives []io.Closer
+	closeFiles = func() {
+		for _, archive := range archives {
+			archive.Close()
+		}
+	}
+
+	files = map[string]audioFile{}
+	add := func(name string, open audioFile) error {
+		if _, dup := files[name]; dup {
+			return invalidRequest(fmt.Sprintf("More than one file is named %s", name))
+		}
+		files[name] = open
+		return nil
+	}
+
+	for _, header := range form.File["audio_files"] {
+		if err := add(header.Filename, func() (io.ReadCloser, error) { return header.Open() }); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, header := range form.File["archive"] {
+		file, err := header.Open()
+		if err != nil {
+			closeFiles()
+			return nil, nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		archives = append(archives, file)
+
+		archive, err := zip.NewReader(file, header.Size)
+		if err != nil {
+			closeFiles()
+			return nil, nil, invalidRequest(fmt.Sprintf("%s is not a valid ZIP archive", header.Filename))
+		}
+		for _, entry := range archive.File {
+			if entry.FileInfo().IsDir() {
+				continue
+			}
+			if err := add(entry.Name, entry.Open); err != nil {
+				closeFiles()
+				return nil, nil, err
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		closeFiles()
+		return nil, nil, invalidRequest("audio_files or archive is required")
+	}
+	return files, closeFiles, nil
+}
+
+// batchItem builds the analysis of one manifest entry. Its audio is read,
+// up to MaxFileBytes, when the batch gets to it.
+func (h *SessionBatchHandler) batchItem(manifest *BatchManifest, entry BatchManifestItem, files map[string]audioFile) (services.BatchItem, error) {
+	req := services.CreateSessionRequest{
+		ExpectedText: entry.ExpectedText,
+		UserID:       entry.UserID,
+		PromptID:     entry.PromptID,
+		SessionID:    entry.SessionID,
+		Filename:     entry.File,
+		Model:        entry.Model,
+	}
+	if req.ExpectedText == "" {
+		req.ExpectedText = manifest.ExpectedText
+	}
+	if req.PromptID == nil {
+		req.PromptID = manifest.PromptID
+	}
+	if req.Model == "" {
+		req.Model = manifest.Model
+	}
+	if req.ExpectedText == "" {
+		return services.BatchItem{}, invalidRequest("expected_text is required")
+	}
+
+	open, ok := files[entry.File]
+	if !ok {
+		return services.BatchItem{}, invalidRequest(fmt.Sprintf("No uploaded file is named %s", entry.File))
+	}
+
+	readAudio := func() ([]byte, error) {
+		file, err := open()
+		if err != nil {
+			return nil, invalidRequest(fmt.Sprintf("%s could not be opened", entry.File))
+		}
+		defer file.Close()
+
+		// Read one byte past the limit to tell a full file from a cut one
+		data, err := io.ReadAll(io.LimitReader(file, int64(h.options.MaxFileBytes)+1))
+		if err != nil {
+			return nil, invalidRequest(fmt.Sprintf("%s could not be read", entry.File))
+		}
+		if len(data) > h.options.MaxFileBytes {
+			return nil, invalidRequest(fmt.Sprintf("%s is larger than %d bytes", entry.File, h.options.MaxFileBytes))
+		}
+		metrics.ObserveUpload(len(data))
+		return data, nil
+	}
+
+	return services.BatchItem{Request: req, ReadAudio: readAudio}, nil
+}
+
+// fail records why an item wasn't analysed, in the error envelope's shape
+func (r *batchItemResult) fail(c *gin.Context, err error) {
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.Request.Context(), "batch item failed", "index", r.Index, "error", err)
+	}
+	r.Status = batchFailed
+	r.Error = &errorBody{apiErr, logging.RequestID(c.Request.Context())}
+}
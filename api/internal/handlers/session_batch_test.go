@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchPart is one part of a batch upload: a form field, or a file when
+// filename is set
+type batchPart struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+func batchContext(t *testing.T, parts ...batchPart) *gin.Context {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range parts {
+		var err error
+		if part.filename == "" {
+			err = writer.WriteField(part.field, string(part.content))
+		} else {
+			var w io.Writer
+			if w, err = writer.CreateFormFile(part.field, part.filename); err == nil {
+				_, err = w.Write(part.content)
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/sessions/batch", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// wantInvalid fails unless err is a 400 invalid_request, or nil when
+// wantErr is false
+func wantInvalid(t *testing.T, err error, wantErr bool) {
+	t.Helper()
+	if !wantErr {
+		if err != nil {
+			t.Fatalf("err = %v, want none", err)
+		}
+		return
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Fatalf("err = %v, want a 400", err)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	tests := []struct {
+		name      string
+		part      batchPart
+		wantItems int
+		wantErr   bool
+	}{
+		{name: "form field", part: batchPart{field: "manifest", content: []byte(`{"items": [{"file": "a.webm"}]}`)}, wantItems: 1},
+		{name: "file part", part: batchPart{field: "manifest", filename: "manifest.json", content: []byte(`{"items": [{"file": "a.webm"}, {"file": "b.webm"}]}`)}, wantItems: 2},
+		{name: "missing", part: batchPart{field: "other", content: []byte("x")}, wantErr: true},
+		{name: "not JSON", part: batchPart{field: "manifest", content: []byte(`{"items": [`)}, wantErr: true},
+		{name: "no items", part: batchPart{field: "manifest", content: []byte(`{"items": []}`)}, wantErr: true},
+		{name: "too many items", part: batchPart{field: "manifest", content: []byte(`{"items": [{"file": "a"}, {"file": "b"}, {"file": "c"}]}`)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewSessionBatchHandler(nil, BatchOptions{MaxItems: 2, MaxFileBytes: 1 << 10})
+			manifest, err := h.readManifest(batchContext(t, tt.part))
+			wantInvalid(t, err, tt.wantErr)
+			if !tt.wantErr && len(manifest.Items) != tt.wantItems {
+				t.Errorf("items = %d, want %d", len(manifest.Items), tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestReadManifestTooLarge(t *testing.T) {
+	c := batchContext(t,
+		batchPart{field: "manifest", content: []byte(`{"items": [{"file": "a.webm"}]}`)},
+		batchPart{field: "audio_files", filename: "a.webm", content: make([]byte, 2<<10)},
+	)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 1<<10)
+
+	h := NewSessionBatchHandler(nil, BatchOptions{MaxItems: 2, MaxFileBytes: 1 << 10})
+	_, err := h.readManifest(c)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("err = %v, want a 413", err)
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		parts     []batchPart
+		wantFiles map[string]string
+		wantErr   bool
+	}{
+		{
+			name: "audio files and archive",
+			parts: []batchPart{
+				{field: "audio_files", filename: "a.webm", content: []byte("alice")},
+				{field: "archive", filename: "class.zip", content: zipArchive(t, map[string]string{"class/b.webm": "bob", "class/": ""})},
+			},
+			wantFiles: map[string]string{"a.webm": "alice", "class/b.webm": "bob"},
+		},
+		{
+			name: "same name twice",
+			parts: []batchPart{
+				{field: "audio_files", filename: "a.webm", content: []byte("alice")},
+				{field: "archive", filename: "class.zip", content: zipArchive(t, map[string]string{"a.webm": "other"})},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "archive not a ZIP",
+			parts:   []batchPart{{field: "archive", filename: "class.zip", content: []byte("not a zip")}},
+			wantErr: true,
+		},
+		{
+			name:    "no audio",
+			parts:   []batchPart{{field: "manifest", content: []byte(`{}`)}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewSessionBatchHandler(nil, BatchOptions{MaxItems: 10, MaxFileBytes: 1 << 10})
+			files, closeFiles, err := h.collectFiles(batchContext(t, tt.parts...))
+			wantInvalid(t, err, tt.wantErr)
+			if tt.wantErr {
+				return
+			}
+			defer closeFiles()
+
+			if len(files) != len(tt.wantFiles) {
+				t.Errorf("files = %d, want %d", len(files), len(tt.wantFiles))
+			}
+			for name, want := range tt.wantFiles {
+				open, ok := files[name]
+				if !ok {
+					t.Errorf("%s is missing", name)
+					continue
+				}
+				file, err := open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(file)
+				file.Close()
+				if string(data) != want {
+					t.Errorf("%s = %q, want %q", name, data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchItem(t *testing.T) {
+	manifestPrompt, itemPrompt := "prompt-1", "prompt-2"
+	manifest := &BatchManifest{ExpectedText: "the cat sat", PromptID: &manifestPrompt, Model: "fast"}
+	files := map[string]audioFile{
+		"a.webm":   func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader([]byte("alice"))), nil },
+		"big.webm": func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(make([]byte, 11))), nil },
+	}
+
+	tests := []struct {
+		name       string
+		manifest   *BatchManifest
+		entry      BatchManifestItem
+		wantText   string
+		wantPrompt *string
+		wantModel  string
+		wantErr    bool
+		// wantReadErr is set when the item builds but its audio can't be read
+		wantReadErr bool
+	}{
+		{
+			name:       "manifest defaults",
+			manifest:   manifest,
+			entry:      BatchManifestItem{File: "a.webm"},
+			wantText:   "the cat sat",
+			wantPrompt: &manifestPrompt,
+			wantModel:  "fast",
+		},
+		{
+			name:       "item overrides",
+			manifest:   manifest,
+			entry:      BatchManifestItem{File: "a.webm", ExpectedText: "the dog ran", PromptID: &itemPrompt, Model: "accurate"},
+			wantText:   "the dog ran",
+			wantPrompt: &itemPrompt,
+			wantModel:  "accurate",
+		},
+		{
+			name:     "no expected text",
+			manifest: &BatchManifest{},
+			entry:    BatchManifestItem{File: "a.webm"},
+			wantErr:  true,
+		},
+		{
+			name:     "no such file",
+			manifest: manifest,
+			entry:    BatchManifestItem{File: "missing.webm"},
+			wantErr:  true,
+		},
+		{
+			name:        "file too large",
+			manifest:    manifest,
+			entry:       BatchManifestItem{File: "big.webm"},
+			wantText:    "the cat sat",
+			wantPrompt:  &manifestPrompt,
+			wantModel:   "fast",
+			wantReadErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewSessionBatchHandler(nil, BatchOptions{MaxItems: 10, MaxFileBytes: 10})
+			item, err := h.batchItem(tt.manifest, tt.entry, files)
+			wantInvalid(t, err, tt.wantErr)
+			if tt.wantErr {
+				return
+			}
+
+			req := item.Request
+			if req.ExpectedText != tt.wantText || req.PromptID != tt.wantPrompt || req.Model != tt.wantModel {
+				t.Errorf("request = %q, %v, %q, want %q, %v, %q",
+					req.ExpectedText, req.PromptID, req.Model, tt.wantText, tt.wantPrompt, tt.wantModel)
+			}
+
+			audio, err := item.ReadAudio()
+			wantInvalid(t, err, tt.wantReadErr)
+			if !tt.wantReadErr && string(audio) != "alice" {
+				t.Errorf("audio = %q, want %q", audio, "alice")
+			}
+		})
+	}
+}
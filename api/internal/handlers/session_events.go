@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/progress"
+	"speaktrainer-api/internal/services"
+)
+
+const (
+	// eventsKeepAlive is how often an idle feed sends a comment, so proxies
+	// don't close it
+	eventsKeepAlive = 15 * time.Second
+	// eventsMaxWait is how long a feed waits for an analysis to finish
+	eventsMaxWait = 5 * time.Minute
+)
+
+type SessionEventsHandler struct {
+	sessionService *services.SessionService
+	progress       *progress.Bus
+
+	shutdownOnce sync.Once
+	shutdown     chan struct{}
+}
+
+func NewSessionEventsHandler(sessionService *services.SessionService, progressBus *progress.Bus) *SessionEventsHandler {
+	return &SessionEventsHandler{
+		sessionService: sessionService,
+		progress:       progressBus,
+		shutdown:       make(chan struct{}),
+	}
+}
+
+// Shutdown ends every open feed, which the server would otherwise wait for
+// when it stops
+func (h *SessionEventsHandler) Shutdown() {
+	h.shutdownOnce.Do(func() { close(h.shutdown) })
+}
+
+// Events is a Server-Sent Events feed of an analysis's stages. Clients
+// choose the session ID up front and may connect before the upload starts.
+// Since anyone can name any ID, the feed only shows the caller's own
+// analyses. It ends after the saved or failed stage.
+func (h *SessionEventsHandler) Events(c *gin.Context) {
+	id := c.Param("id")
+	tenant := currentTenant(c)
+	userID := currentUserID(c)
+
+	// Subscribe before looking the session up, so a save in between isn't
+	// missed
+	events, unsubscribe := h.progress.Subscribe(id)
+	defer unsubscribe()
+
+	session, err := h.sessionService.GetSessionByID(tenant, id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	if session != nil && !ownedByCaller(session.UserID, userID) {
+		fail(c, services.ErrSessionNotFound)
+		return
+	}
+
+	// Feeds outlive the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		fail(c, err)
+		return
+	}
+	// Set before the status is sent, since the headers go out with the
+	// first flush
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	send := func(event progress.Event) bool {
+		c.SSEvent(event.Stage, event)
+		c.Writer.Flush()
+		return event.Final()
+	}
+
+	if session != nil {
+		send(progress.Event{
+			SessionID:     session.ID,
+			Stage:         progress.StageSaved,
+			At:            session.CreatedAt,
+			Transcription: session.Transcription,
+			Score:         &session.Score,
+		})
+		return
+	}
+	if event, ok := h.progress.Latest(id); ok && visibleTo(event, tenant, userID) {
+		if send(event) {
+			return
+		}
+	} else {
+		c.Writer.Flush()
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	timeout := time.NewTimer(eventsMaxWait)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			if visibleTo(event, tenant, userID) && send(event) {
+				return
+			}
+		case <-keepAlive.C:
+			// A watcher that fell behind may have missed the outcome
+			if event, ok := h.progress.Latest(id); ok && event.Final() && visibleTo(event, tenant, userID) {
+				send(event)
+				return
+			}
+			if _, err := c.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-timeout.C:
+			return
+		case <-c.Request.Context().Done():
+			return
+		case <-h.shutdown:
+			return
+		}
+	}
+}
+
+// visibleTo keeps analyses of other organisations and other users out of
+// a feed
+func visibleTo(event progress.Event, tenant services.Tenant, userID string) bool {
+	return event.OrganisationID == tenant.OrganisationID && event.UserID == userID
+}
+
+// ownedByCaller reports whether a session recorded for owner belongs to
+// the caller. Anonymous sessions belong to anonymous callers.
+func ownedByCaller(owner *string, userID string) bool {
+	if owner == nil {
+		return userID == ""
+	}
+	return *owner == userID
+}
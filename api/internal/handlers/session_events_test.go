@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"speaktrainer-api/internal/dbtest"
+	"speaktrainer-api/internal/progress"
+	"speaktrainer-api/internal/services"
+)
+
+func TestEventsForSessionNotStarted(t *testing.T) {
+	db, mock := dbtest.NewMock(t)
+	mock.ExpectQuery(`FROM "sessions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	bus := progress.NewBus(nil)
+	handler := NewSessionEventsHandler(services.NewSessionService(db, nil, nil, nil, bus), bus)
+
+	router := newTestRouter()
+	router.GET("/sessions/:id/events", handler.Events)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/sessions/session-1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The headers arrive before any event, while the feed waits
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	bus.Publish(context.Background(), progress.Event{SessionID: "session-1", Stage: progress.StageFailed})
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "event:" + progress.StageFailed; strings.TrimSpace(line) != want {
+		t.Errorf("first line = %q, want %q", line, want)
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	org := services.Tenant{OrganisationID: "org-1"}
+	tests := []struct {
+		name   string
+		event  progress.Event
+		tenant services.Tenant
+		userID string
+		want   bool
+	}{
+		{name: "own analysis", event: progress.Event{OrganisationID: "org-1", UserID: "user-1"}, tenant: org, userID: "user-1", want: true},
+		{name: "another user's analysis", event: progress.Event{OrganisationID: "org-1", UserID: "user-2"}, tenant: org, userID: "user-1"},
+		{name: "another organisation's analysis", event: progress.Event{OrganisationID: "org-2", UserID: "user-1"}, tenant: org, userID: "user-1"},
+		{name: "anonymous analysis, anonymous caller", event: progress.Event{}, want: true},
+		{name: "a user's analysis, anonymous caller", event: progress.Event{UserID: "user-1"}},
+		{name: "anonymous analysis, signed in caller", event: progress.Event{}, userID: "user-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visibleTo(tt.event, tt.tenant, tt.userID); got != tt.want {
+				t.Errorf("visibleTo = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventsForAnotherUsersSession(t *testing.T) {
+	db, mock := dbtest.NewMock(t)
+	mock.ExpectQuery(`FROM "sessions"`).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow("session-1", "user-2"))
+	mock.ExpectQuery(`FROM "session_feedbacks"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	bus := progress.NewBus(nil)
+	handler := NewSessionEventsHandler(services.NewSessionService(db, nil, nil, nil, bus), bus)
+
+	router := newTestRouter()
+	router.GET("/sessions/:id/events", handler.Events)
+
+	req := httptest.NewRequest(http.MethodGet, "/sessions/session-1/events", nil)
+	req.Header.Set(UserIDHeader, "user-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNotFound, rec.Body)
+	}
+}
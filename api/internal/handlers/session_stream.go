@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"speaktrainer-api/internal/logging"
+	"speaktrainer-api/internal/metrics"
+	"speaktrainer-api/internal/services"
+)
+
+// Message types of the streaming protocol. The client sends start, then
+// audio as binary messages, then end (or cancel); the server answers with
+// ready, any number of interims, and finally result or error.
+const (
+	streamStart   = "start"
+	streamEnd     = "end"
+	streamCancel  = "cancel"
+	streamReady   = "ready"
+	streamInterim = "interim"
+	streamResult  = "result"
+	streamError   = "error"
+)
+
+const (
+	// maxStreamMessage caps one message; audio arrives in many of them
+	maxStreamMessage = 1 << 20
+	streamWriteWait  = 10 * time.Second
+	// defaultStreamFilename hints the container to the ML service when the
+	// client doesn't name one; MediaRecorder produces WebM
+	defaultStreamFilename = "stream.webm"
+)
+
+// StreamOptions bounds a streaming analysis
+type StreamOptions struct {
+	MaxBytes        int
+	InterimInterval time.Duration
+	IdleTimeout     time.Duration
+	// CheckOrigin reports whether a page at origin may open the socket.
+	// Browsers don't apply CORS to WebSockets, so this is the only guard.
+	CheckOrigin func(origin string) bool
+}
+
+type SessionStreamHandler struct {
+	sessionService *services.SessionService
+	options        StreamOptions
+	upgrader       websocket.Upgrader
+
+	// The server doesn't track hijacked connections, so the handler keeps
+	// its own: streams still receiving audio, and every stream running
+	mu        sync.Mutex
+	closing   bool
+	receiving map[*analysisStream]struct{}
+	running   sync.WaitGroup
+}
+
+// streamRequest is a control message from the client. Only start carries
+// fields, the same ones as the analyze form, except that user_id may only
+// name the caller, whom the session is recorded for.
+type streamRequest struct {
+	Type         string  `json:"type"`
+	SessionID    string  `json:"session_id"`
+	ExpectedText string  `json:"expected_text"`
+	UserID       *string `json:"user_id"`
+	PromptID     *string `json:"prompt_id"`
+	Filename     string  `json:"filename"`
+	Model        string  `json:"model"`
+}
+
+type streamEvent struct {
+	Type          string     `json:"type"`
+	Transcription string     `json:"transcription,omitempty"`
+	Bytes         int        `json:"bytes,omitempty"`
+	Result        gin.H      `json:"result,omitempty"`
+	Error         *errorBody `json:"error,omitempty"`
+}
+
+func NewSessionStreamHandler(sessionService *services.SessionService, options StreamOptions) *SessionStreamHandler {
+	h := &SessionStreamHandler{
+		sessionService: sessionService,
+		options:        options,
+		receiving:      map[*analysisStream]struct{}{},
+	}
+	if options.CheckOrigin != nil {
+		h.upgrader.CheckOrigin = func(r *http.Request) bool {
+			return options.CheckOrigin(r.Header.Get("Origin"))
+		}
+	}
+	return h
+}
+
+// Stream analyses audio sent over a WebSocket while the learner speaks,
+// sending interim transcriptions as it goes and the analysis at the end
+func (h *SessionStreamHandler) Stream(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already answered with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	stream := &analysisStream{
+		handler: h,
+		conn:    conn,
+		tenant:  currentTenant(c),
+		userID:  currentUserID(c),
+	}
+	if !h.track(stream) {
+		stream.close(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer h.running.Done()
+	defer h.stopReceiving(stream)
+
+	stream.run(c.Request.Context())
+}
+
+// Shutdown closes every stream still receiving audio and turns new ones
+// away. Streams already being analysed are left to finish; Wait for them.
+func (h *SessionStreamHandler) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closing = true
+	for stream := range h.receiving {
+		stream.close(websocket.CloseGoingAway, "server shutting down")
+		// Unblocks the read the stream is waiting in
+		stream.conn.SetReadDeadline(time.Now())
+	}
+}
+
+// Wait blocks until every stream has ended, or ctx is done. Streams that
+// start meanwhile are turned away.
+func (h *SessionStreamHandler) Wait(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track registers a new stream, unless the server is shutting down
+func (h *SessionStreamHandler) track(stream *analysisStream) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closing {
+		return false
+	}
+	h.receiving[stream] = struct{}{}
+	h.running.Add(1)
+	return true
+}
+
+// stopReceiving stops Shutdown from closing stream, once it has all its
+// audio and is being analysed
+func (h *SessionStreamHandler) stopReceiving(stream *analysisStream) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.receiving, stream)
+}
+
+// analysisStream is one connection. Gorilla allows one concurrent writer,
+// and the interim loop writes alongside the reader, so writes take writeMu.
+type analysisStream struct {
+	handler *SessionStreamHandler
+	conn    *websocket.Conn
+	tenant  services.Tenant
+	userID  string
+	writeMu sync.Mutex
+
+	mu    sync.Mutex
+	audio []byte
+}
+
+func (s *analysisStream) run(ctx context.Context) {
+	s.conn.SetReadLimit(maxStreamMessage)
+
+	start, err := s.readStart()
+	if err != nil {
+		// Only protocol errors are worth answering; a read error means the
+		// client is gone or never spoke
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			s.fail(ctx, err)
+		}
+		return
+	}
+	s.send(streamEvent{Type: streamReady})
+
+	interimCtx, stopInterim := context.WithCancel(ctx)
+	interimDone := make(chan struct{})
+	go func() {
+		defer close(interimDone)
+		s.runInterims(interimCtx, start.Filename)
+	}()
+	defer func() {
+		stopInterim()
+		<-interimDone
+	}()
+
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(s.handler.options.IdleTimeout))
+		kind, data, err := s.conn.ReadMessage()
+		if err != nil {
+			// The client went away or fell silent; there is no one to tell
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.DebugContext(ctx, "analysis stream closed", "error", err)
+			}
+			return
+		}
+
+		if kind == websocket.BinaryMessage {
+			if err := s.appendAudio(data); err != nil {
+				s.fail(ctx, err)
+				return
+			}
+			continue
+		}
+
+		var msg streamRequest
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.fail(ctx, invalidRequest("Control messages must be JSON"))
+			return
+		}
+		switch msg.Type {
+		case streamEnd:
+			// Interims are pointless once the full analysis starts
+			stopInterim()
+			<-interimDone
+			s.handler.stopReceiving(s)
+			s.finish(ctx, start)
+			return
+		case streamCancel:
+			s.close(websocket.CloseNormalClosure, "cancelled")
+			return
+		default:
+			s.fail(ctx, invalidRequest("Expected audio, end or cancel"))
+			return
+		}
+	}
+}
+
+// readStart waits for the start message, which says what is being read
+func (s *analysisStream) readStart() (streamRequest, error) {
+	var start streamRequest
+
+	s.conn.SetReadDeadline(time.Now().Add(s.handler.options.IdleTimeout))
+	kind, data, err := s.conn.ReadMessage()
+	if err != nil {
+		return start, err
+	}
+	if kind != websocket.TextMessage || json.Unmarshal(data, &start) != nil || start.Type != streamStart {
+		return start, invalidRequest("The first message must be start")
+	}
+	if start.ExpectedText == "" {
+		return start, invalidRequest("expected_text is required")
+	}
+	if start.UserID != nil && *start.UserID != s.userID {
+		return start, &APIError{Status: http.StatusForbidden, Code: CodeForbidden, Message: "Streams can only record sessions for the caller"}
+	}
+
+	start.Filename = filepath.Base(start.Filename)
+	if start.Filename == "." || start.Filename == "/" {
+		start.Filename = defaultStreamFilename
+	}
+	return start, nil
+}
+
+func (s *analysisStream) appendAudio(chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.audio)+len(chunk) > s.handler.options.MaxBytes {
+		return invalidRequest("Audio exceeds the maximum length")
+	}
+	s.audio = append(s.audio, chunk...)
+	return nil
+}
+
+// buffered returns the audio so far. Appends never touch it, so it can be
+// read without the lock.
+func (s *analysisStream) buffered() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.audio[:len(s.audio):len(s.audio)]
+}
+
+// runInterims transcribes the audio so far every InterimInterval while it
+// keeps growing. Calls don't overlap, so a slow ML service means fewer
+// interims rather than a queue of them.
+func (s *analysisStream) runInterims(ctx context.Context, filename string) {
+	ticker := time.NewTicker(s.handler.options.InterimInterval)
+	defer ticker.Stop()
+
+	transcribed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		audio := s.buffered()
+		if len(audio) == transcribed {
+			continue
+		}
+
+		text, err := s.handler.sessionService.Transcribe(ctx, audio, filename)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// Interims are a courtesy; the final analysis reports real failures
+			slog.WarnContext(ctx, "interim transcription failed", "error", err)
+			continue
+		}
+
+		transcribed = len(audio)
+		s.send(streamEvent{Type: streamInterim, Transcription: text, Bytes: len(audio)})
+	}
+}
+
+// finish runs the full analysis on everything received and persists it
+func (s *analysisStream) finish(ctx context.Context, start streamRequest) {
+	audio := s.buffered()
+	if len(audio) == 0 {
+		s.fail(ctx, invalidRequest("No audio was sent"))
+		return
+	}
+	metrics.ObserveUpload(len(audio))
+
+	result, err := s.handler.sessionService.AnalyzePronunciation(ctx, services.CreateSessionRequest{
+		Tenant:       s.tenant,
+		SessionID:    start.SessionID,
+		ExpectedText: start.ExpectedText,
+		UserID:       s.callerID(),
+		PromptID:     start.PromptID,
+		AudioData:    audio,
+		Filename:     start.Filename,
+		Model:        start.Model,
+	})
+	if err != nil {
+		s.fail(ctx, err)
+		return
+	}
+
+	s.send(streamEvent{Type: streamResult, Result: analysisResponse(result, s.tenant)})
+	s.close(websocket.CloseNormalClosure, "")
+}
+
+// callerID is the user the session is recorded for, nil when anonymous
+func (s *analysisStream) callerID() *string {
+	if s.userID == "" {
+		return nil
+	}
+	return &s.userID
+}
+
+// fail sends the error envelope ErrorHandler would have written, then
+// closes the socket
+func (s *analysisStream) fail(ctx context.Context, err error) {
+	apiErr := toAPIError(err)
+	code := websocket.ClosePolicyViolation
+	if apiErr.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "analysis stream failed", "error", err)
+		code = websocket.CloseInternalServerErr
+	}
+
+	s.send(streamEvent{Type: streamError, Error: &errorBody{apiErr, logging.RequestID(ctx)}})
+	s.close(code, apiErr.Code)
+}
+
+func (s *analysisStream) send(event streamEvent) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+	if err := s.conn.WriteJSON(event); err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+		slog.Debug("analysis stream write failed", "error", err)
+	}
+}
+
+func (s *analysisStream) close(code int, reason string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(streamWriteWait))
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialStream serves h on a test server and opens a stream as userID
+func dialStream(t *testing.T, h *SessionStreamHandler, userID string) *websocket.Conn {
+	t.Helper()
+	router := newTestRouter()
+	router.GET("/stream", h.Stream)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	header := http.Header{}
+	if userID != "" {
+		header.Set(UserIDHeader, userID)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/stream", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func newTestStreamHandler() *SessionStreamHandler {
+	return NewSessionStreamHandler(nil, StreamOptions{
+		MaxBytes:        1 << 20,
+		InterimInterval: time.Hour,
+		IdleTimeout:     time.Minute,
+	})
+}
+
+func TestStreamStartUserID(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller string
+		userID string
+		want   string
+	}{
+		{"caller's own ID", "user-1", "user-1", streamReady},
+		{"another user", "user-1", "user-2", streamError},
+		{"anonymous caller naming a user", "", "user-2", streamError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dialStream(t, newTestStreamHandler(), tt.caller)
+			start := streamRequest{Type: streamStart, ExpectedText: "hello", UserID: &tt.userID}
+			if err := conn.WriteJSON(start); err != nil {
+				t.Fatal(err)
+			}
+
+			var event streamEvent
+			if err := conn.ReadJSON(&event); err != nil {
+				t.Fatal(err)
+			}
+			if event.Type != tt.want {
+				t.Fatalf("event = %+v, want %s", event, tt.want)
+			}
+			if event.Type == streamError && event.Error.Code != CodeForbidden {
+				t.Errorf("error code = %s, want %s", event.Error.Code, CodeForbidden)
+			}
+		})
+	}
+}
+
+func TestStreamShutdown(t *testing.T) {
+	h := newTestStreamHandler()
+	conn := dialStream(t, h, "")
+	if err := conn.WriteJSON(streamRequest{Type: streamStart, ExpectedText: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	var event streamEvent
+	if err := conn.ReadJSON(&event); err != nil || event.Type != streamReady {
+		t.Fatalf("event = %+v, %v, want ready", event, err)
+	}
+
+	h.Shutdown()
+
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("read after shutdown = %v, want a going away close", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Wait(ctx); err != nil {
+		t.Fatalf("Wait = %v, want the stream to have ended", err)
+	}
+
+	// Streams opened after shutdown are turned away
+	late := dialStream(t, h, "")
+	if _, _, err := late.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("read on a late stream = %v, want a going away close", err)
+	}
+}
@@ -1,45 +1,63 @@
 package handlers
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"speaktrainer-api/internal/metrics"
 	"speaktrainer-api/internal/models"
 	"speaktrainer-api/internal/services"
+	"speaktrainer-api/internal/tracing"
 )
 
 type SessionHandler struct {
 	sessionService *services.SessionService
 }
 
+type UpdateSessionRequest struct {
+	Notes     *string `json:"notes"`
+	Favourite *bool   `json:"favourite"`
+}
+
 func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
 	return &SessionHandler{sessionService: sessionService}
 }
 
 func (h *SessionHandler) AnalyzePronunciation(c *gin.Context) {
+	// Parsing the multipart upload gets its own span, apart from the analysis
+	_, parseSpan := tracing.Tracer().Start(c.Request.Context(), "parse upload")
+
 	// Get form data - just need expected_text now
 	expectedText := c.PostForm("expected_text")
 	if expectedText == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "expected_text is required"})
+		parseSpan.End()
+		fail(c, invalidRequest("expected_text is required"))
 		return
 	}
 
 	// Get uploaded file
 	file, header, err := c.Request.FormFile("audio_file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "audio_file is required"})
+		parseSpan.End()
+		fail(c, invalidRequest("audio_file is required"))
 		return
 	}
 	defer file.Close()
 
 	// Read file data
 	audioData, err := io.ReadAll(file)
+	parseSpan.SetAttributes(attribute.Int("upload.bytes", len(audioData)))
+	parseSpan.End()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audio file"})
+		fail(c, fmt.Errorf("failed to read audio file: %w", err))
 		return
 	}
+	metrics.ObserveUpload(len(audioData))
 
 	// Optional: Get user ID from auth (for now, we'll skip auth)
 	var userID *string
@@ -47,85 +65,265 @@ func (h *SessionHandler) AnalyzePronunciation(c *gin.Context) {
 		userID = &uid
 	}
 
+	// Optionally link the session to the prompt it was practised from
+	var promptID *string
+	if pid := c.PostForm("prompt_id"); pid != "" {
+		promptID = &pid
+	}
+
 	// Create session request - no more prompt lookup needed
+	tenant := currentTenant(c)
 	req := services.CreateSessionRequest{
+		Tenant:       tenant,
+		SessionID:    c.PostForm("session_id"),
 		ExpectedText: expectedText,
 		UserID:       userID,
+		PromptID:     promptID,
 		AudioData:    audioData,
 		Filename:     header.Filename,
+		Model:        c.PostForm("model"),
 	}
 
 	// Analyze pronunciation
-	result, err := h.sessionService.AnalyzePronunciation(req)
+	result, err := h.sessionService.AnalyzePronunciation(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
 	// Return complete analysis result
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusOK, analysisResponse(result, tenant))
+}
+
+// analysisResponse is the body returned for a new analysis
+func analysisResponse(result *services.SessionAnalysisResult, tenant services.Tenant) gin.H {
+	return gin.H{
 		"session_id":         result.Session.ID,
 		"expected_text":      result.Session.ExpectedText,
 		"transcription":      result.Session.Transcription,
 		"score":              result.Session.Score,
+		"rating":             tenant.Settings.Rating(result.Session.Score),
+		"cached":             result.Session.Cached,
+		"ml_backend":         result.Session.MLBackend,
+		"ml_model":           result.Session.MLModel,
 		"expected_phonemes":  result.AnalysisDetails.ExpectedPhonemes,
 		"actual_phonemes":    result.AnalysisDetails.ActualPhonemes,
 		"phoneme_diff":       result.AnalysisDetails.Diff,
 		"analysis_details":   result.AnalysisDetails,
 		"created_at":         result.Session.CreatedAt,
-	})
+	}
 }
 
 func (h *SessionHandler) GetSession(c *gin.Context) {
 	id := c.Param("id")
 
-	session, err := h.sessionService.GetSessionByID(id)
+	session, err := h.sessionService.GetSessionByID(currentTenant(c), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if session == nil {
+		fail(c, services.ErrSessionNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *SessionHandler) UpdateSession(c *gin.Context) {
+	id := c.Param("id")
+
+	var req UpdateSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	if _, ok := h.loadOwnedSession(c, id); !ok {
+		return
+	}
+
+	session, err := h.sessionService.UpdateSession(currentTenant(c), id, services.UpdateSessionRequest{
+		Notes:     req.Notes,
+		Favourite: req.Favourite,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
 	if session == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		fail(c, services.ErrSessionNotFound)
 		return
 	}
 
 	c.JSON(http.StatusOK, session)
 }
 
+func (h *SessionHandler) DeleteSession(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, ok := h.loadOwnedSession(c, id); !ok {
+		return
+	}
+
+	err := h.sessionService.DeleteSession(currentTenant(c), id)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session deleted successfully"})
+}
+
+// loadOwnedSession fetches a session and checks that the caller owns it,
+// recording the error and returning false otherwise
+func (h *SessionHandler) loadOwnedSession(c *gin.Context, id string) (*models.Session, bool) {
+	userID := currentUserID(c)
+	if userID == "" {
+		fail(c, errUnauthenticated)
+		return nil, false
+	}
+
+	session, err := h.sessionService.GetSessionByID(currentTenant(c), id)
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	if session == nil {
+		fail(c, services.ErrSessionNotFound)
+		return nil, false
+	}
+
+	if session.UserID == nil || *session.UserID != userID {
+		fail(c, &APIError{Status: http.StatusForbidden, Code: CodeForbidden, Message: "You do not own this session"})
+		return nil, false
+	}
+
+	return session, true
+}
+
 func (h *SessionHandler) GetSessions(c *gin.Context) {
 	// Parse query parameters
 	limitStr := c.DefaultQuery("limit", "10")
 	offsetStr := c.DefaultQuery("offset", "0")
-	userID := c.Query("user_id")
+	cursorStr := c.Query("cursor")
 
 	limit, err := strconv.Atoi(limitStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+	if err != nil || limit < 1 || limit > services.MaxSessionPageSize {
+		fail(c, invalidRequest(fmt.Sprintf("limit must be between 1 and %d", services.MaxSessionPageSize)))
 		return
 	}
 
 	offset, err := strconv.Atoi(offsetStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+	if err != nil || offset < 0 {
+		fail(c, invalidRequest("Invalid offset parameter"))
 		return
 	}
 
-	var sessions []models.Session
-	if userID != "" {
-		sessions, err = h.sessionService.GetSessionsByUser(userID, limit, offset)
-	} else {
-		sessions, err = h.sessionService.GetAllSessions(limit, offset)
+	page := services.SessionPage{
+		Limit:        limit,
+		Offset:       offset,
+		IncludeTotal: c.Query("include_total") == "true",
+	}
+
+	if cursorStr != "" {
+		if offset > 0 {
+			fail(c, invalidRequest("cursor and offset cannot be combined"))
+			return
+		}
+		cursor, err := services.DecodeSessionCursor(cursorStr)
+		if err != nil {
+			fail(c, invalidRequest("Invalid cursor parameter"))
+			return
+		}
+		page.Cursor = cursor
+	}
+
+	filter, err := parseSessionFilter(c)
+	if err != nil {
+		fail(c, err)
+		return
 	}
 
+	list, err := h.sessionService.ListSessions(currentTenant(c), filter, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"sessions": sessions,
+	response := gin.H{
+		"sessions": list.Sessions,
 		"limit":    limit,
 		"offset":   offset,
-	})
-}
\ No newline at end of file
+	}
+	if list.NextCursor != "" {
+		response["next_cursor"] = list.NextCursor
+	}
+	if list.Total != nil {
+		response["total"] = *list.Total
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func parseSessionFilter(c *gin.Context) (services.SessionFilter, error) {
+	filter := services.SessionFilter{
+		UserID:       c.Query("user_id"),
+		PromptID:     c.Query("prompt_id"),
+		TextContains: c.Query("q"),
+	}
+
+	if favourite := c.Query("favourite"); favourite != "" {
+		b, err := strconv.ParseBool(favourite)
+		if err != nil {
+			return filter, invalidRequest("Invalid favourite parameter")
+		}
+		filter.Favourite = &b
+	}
+
+	var err error
+	if filter.MinScore, err = parseOptionalInt(c, "min_score"); err != nil {
+		return filter, err
+	}
+	if filter.MaxScore, err = parseOptionalInt(c, "max_score"); err != nil {
+		return filter, err
+	}
+	if filter.From, err = parseOptionalTime(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseOptionalTime(c, "to"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseOptionalInt(c *gin.Context, key string) (*int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, invalidRequest(fmt.Sprintf("Invalid %s parameter", key))
+	}
+	return &n, nil
+}
+
+// parseOptionalTime accepts RFC 3339 timestamps or plain YYYY-MM-DD dates
+func parseOptionalTime(c *gin.Context, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return &t, nil
+	}
+	return nil, invalidRequest(fmt.Sprintf("Invalid %s parameter", key))
+}
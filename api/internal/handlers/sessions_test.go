@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"speaktrainer-api/internal/dbtest"
+	"speaktrainer-api/internal/services"
+)
+
+func TestGetSessionsLimit(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"?limit=1", http.StatusOK},
+		{"?limit=100", http.StatusOK},
+		{"?limit=0", http.StatusBadRequest},
+		{"?limit=-1", http.StatusBadRequest},
+		{"?limit=101", http.StatusBadRequest},
+		{"?limit=ten", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			if tt.status == http.StatusOK {
+				mock.ExpectQuery(`FROM "sessions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+			handler := NewSessionHandler(services.NewSessionService(db, nil, nil, nil, nil))
+
+			router := newTestRouter()
+			router.GET("/sessions", handler.GetSessions)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions"+tt.query, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"speaktrainer-api/internal/services"
+)
+
+const maxDeliveryLogLimit = 200
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+type CreateWebhookRequest struct {
+	URL            string   `json:"url" binding:"required"`
+	Events         []string `json:"events" binding:"required,min=1"`
+	OrganisationID *string  `json:"organisation_id"`
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// CreateWebhook subscribes a URL to events. The signing secret is only in
+// this response.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+
+	sub, secret, err := h.webhookService.CreateWebhook(services.CreateWebhookRequest{
+		Tenant:         currentTenant(c),
+		OrganisationID: req.OrganisationID,
+		URL:            req.URL,
+		Events:         req.Events,
+		CreatedBy:      currentUser(c).ID,
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"webhook": sub, "secret": secret})
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	subs, err := h.webhookService.ListWebhooks(currentTenant(c))
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": subs})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if err := h.webhookService.DeleteWebhook(currentTenant(c), c.Param("id")); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ListDeliveries is a webhook's delivery log, newest first
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > maxDeliveryLogLimit {
+		fail(c, invalidRequest("Invalid limit parameter"))
+		return
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(currentTenant(c), c.Param("id"), limit)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
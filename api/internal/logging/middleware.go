@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is read from incoming requests, echoed on responses and
+// forwarded to the ML service
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// AssignRequestID accepts the caller's X-Request-ID or generates one, and stores
+// it in the request context
+func AssignRequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID only lets through short, printable ASCII IDs so they are
+// safe to log and forward
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog logs one structured line per request, replacing Gin's text logger
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+
+		slog.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
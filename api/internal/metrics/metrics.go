@@ -0,0 +1,124 @@
+// Package metrics defines the Prometheus metrics exported on /metrics
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "speaktrainer"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"method", "route"})
+
+	httpInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served, by route.",
+	}, []string{"route"})
+
+	mlDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ml_request_duration_seconds",
+		Help:      "ML service call latency by backend, endpoint and status.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 5, 10, 20, 30},
+	}, []string{"backend", "endpoint", "status"})
+
+	mlErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ml_request_errors_total",
+		Help:      "Failed ML service calls by backend, endpoint and status.",
+	}, []string{"backend", "endpoint", "status"})
+
+	uploadSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upload_size_bytes",
+		Help:      "Size of uploaded audio files.",
+		Buckets:   prometheus.ExponentialBuckets(16*1024, 2, 10), // 16KiB to 8MiB
+	})
+
+	analysisCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "analysis_cache_lookups_total",
+		Help:      "Analysis cache lookups by where the analysis was found: memory, database or miss.",
+	}, []string{"result"})
+
+	scores = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "analysis_score",
+		Help:      "Distribution of pronunciation scores.",
+		Buckets:   prometheus.LinearBuckets(10, 10, 10), // 10 to 100
+	})
+)
+
+// RegisterDB exports connection pool statistics from sql.DB.Stats()
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Middleware records request counts, latency and in-flight requests. Routes
+// are labelled by their pattern (e.g. /api/sessions/:id) to keep
+// cardinality bounded.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		inFlight := httpInFlight.WithLabelValues(route)
+		inFlight.Inc()
+		start := time.Now()
+
+		c.Next()
+
+		inFlight.Dec()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+// ObserveMLCall records one call to an ML backend. status is the HTTP
+// status code, or 0 if no response was received.
+func ObserveMLCall(backend, endpoint string, status int, duration time.Duration, failed bool) {
+	label := "error"
+	if status != 0 {
+		label = strconv.Itoa(status)
+	}
+
+	mlDuration.WithLabelValues(backend, endpoint, label).Observe(duration.Seconds())
+	if failed {
+		mlErrors.WithLabelValues(backend, endpoint, label).Inc()
+	}
+}
+
+func ObserveUpload(bytes int) {
+	uploadSize.Observe(float64(bytes))
+}
+
+func ObserveScore(score int) {
+	scores.Observe(float64(score))
+}
+
+// ObserveAnalysisCache records one analysis cache lookup; result is memory,
+// database or miss
+func ObserveAnalysisCache(result string) {
+	analysisCache.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// AnalysisCacheEntry is an ML analysis kept so that resubmitting the same
+// recording for the same text doesn't run the model again. Key hashes the
+// audio, the expected text and the model; Backend is the ML backend that
+// ran it. SessionID and UserID are whom the analysis was made for, so the
+// entry is erased with them.
+type AnalysisCacheEntry struct {
+	Key       string    `gorm:"primaryKey"`
+	Response  string    `gorm:"type:jsonb;not null"`
+	Backend   string    `gorm:"not null;default:''"`
+	SessionID *string   `gorm:"index"`
+	UserID    *string   `gorm:"index"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
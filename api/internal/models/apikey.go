@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// API key scopes. A key may only call routes requiring one of its scopes.
+const (
+	ScopePromptsRead      = "prompts:read"
+	ScopePromptsWrite     = "prompts:write"
+	ScopeSessionsRead     = "sessions:read"
+	ScopeSessionsWrite    = "sessions:write"
+	ScopeFeedbackWrite    = "feedback:write"
+	ScopeLeaderboardsRead = "leaderboards:read"
+	ScopeGroupsRead       = "groups:read"
+	ScopeGroupsWrite      = "groups:write"
+	ScopeAccount          = "account"
+	ScopeAdmin            = "admin"
+)
+
+var AllScopes = []string{
+	ScopePromptsRead, ScopePromptsWrite,
+	ScopeSessionsRead, ScopeSessionsWrite,
+	ScopeFeedbackWrite,
+	ScopeLeaderboardsRead,
+	ScopeGroupsRead, ScopeGroupsWrite,
+	ScopeAccount,
+	ScopeAdmin,
+}
+
+// APIKey lets a backend call the API without a user login. Only a hash of
+// the secret is stored; the plaintext is shown once, when the key is made.
+// A key is owned by a user, whose identity it acts with, or by an
+// organisation.
+type APIKey struct {
+	ID             string     `json:"id" gorm:"primaryKey"`
+	Name           string     `json:"name" gorm:"not null"`
+	Prefix         string     `json:"prefix" gorm:"uniqueIndex;not null"`
+	Hash           string     `json:"-" gorm:"not null"`
+	Scopes         []string   `json:"scopes" gorm:"serializer:json;type:jsonb;not null"`
+	UserID         *string    `json:"user_id,omitempty" gorm:"index"`
+	OrganisationID *string    `json:"organisation_id,omitempty" gorm:"index"`
+	RateLimits     string     `json:"rate_limits,omitempty" gorm:"not null;default:''"`
+	CreatedBy      string     `json:"created_by" gorm:"not null"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// HasScope reports whether the key grants scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
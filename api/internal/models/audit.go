@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// ErasureAudit records that an account erasure happened. It deliberately
+// holds no user ID, email or other personal data, only what was removed.
+type ErasureAudit struct {
+	ID                   string    `json:"id" gorm:"primaryKey"`
+	SessionsDeleted      int64     `json:"sessions_deleted"`
+	RecordingsDeleted    int64     `json:"recordings_deleted"`
+	NotificationsDeleted int64     `json:"notifications_deleted"`
+	CommentsDeleted      int64     `json:"comments_deleted"`
+	UserRowDeleted       bool      `json:"user_row_deleted"`
+	CreatedAt            time.Time `json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Rubric scores each aspect of pronunciation from 1 (poor) to 5 (excellent)
+type Rubric struct {
+	Intelligibility int `json:"intelligibility" gorm:"not null"`
+	Stress          int `json:"stress" gorm:"not null"`
+	Intonation      int `json:"intonation" gorm:"not null"`
+	Fluency         int `json:"fluency" gorm:"not null"`
+}
+
+// SessionFeedback is a teacher's assessment of a session, with a thread of
+// comments underneath it
+type SessionFeedback struct {
+	ID        string            `json:"id" gorm:"primaryKey"`
+	SessionID string            `json:"session_id" gorm:"not null;index"`
+	TeacherID string            `json:"teacher_id" gorm:"not null;index"`
+	Rubric    Rubric            `json:"rubric" gorm:"embedded;embeddedPrefix:rubric_"`
+	Summary   string            `json:"summary"`
+	Comments  []FeedbackComment `json:"comments" gorm:"foreignKey:FeedbackID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// FeedbackComment may point at a span of the recording (StartMs/EndMs) or
+// at a word of the session's expected text (WordIndex, zero-based)
+type FeedbackComment struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	FeedbackID string    `json:"feedback_id" gorm:"not null;index"`
+	AuthorID   string    `json:"author_id" gorm:"not null"`
+	Body       string    `json:"body" gorm:"not null"`
+	StartMs    *int      `json:"start_ms,omitempty"`
+	EndMs      *int      `json:"end_ms,omitempty"`
+	WordIndex  *int      `json:"word_index,omitempty"`
+	Word       string    `json:"word,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// Group is a set of users, e.g. a classroom, used to scope leaderboards.
+// A group belongs to the organisation it was created in, and only that
+// organisation's users can join it.
+type Group struct {
+	ID             string        `json:"id" gorm:"primaryKey"`
+	OrganisationID *string       `json:"organisation_id,omitempty" gorm:"index"`
+	Name           string        `json:"name" gorm:"not null"`
+	Members        []GroupMember `json:"members,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+type GroupMember struct {
+	GroupID   string    `json:"group_id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Assignment asks a group's members to practise a prompt, optionally by a
+// due date. A member completes it with their first session for the prompt.
+type Assignment struct {
+	ID             string                 `json:"id" gorm:"primaryKey"`
+	OrganisationID *string                `json:"organisation_id,omitempty" gorm:"index"`
+	GroupID        string                 `json:"group_id" gorm:"not null;index"`
+	Group          *Group                 `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	PromptID       string                 `json:"prompt_id" gorm:"not null;index"`
+	Prompt         *Prompt                `json:"prompt,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	DueAt          *time.Time             `json:"due_at,omitempty"`
+	CreatedBy      string                 `json:"created_by" gorm:"not null"`
+	Completions    []AssignmentCompletion `json:"completions,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// AssignmentCompletion records the session with which a member completed
+// an assignment
+type AssignmentCompletion struct {
+	AssignmentID string    `json:"assignment_id" gorm:"primaryKey"`
+	UserID       string    `json:"user_id" gorm:"primaryKey;index"`
+	SessionID    string    `json:"session_id" gorm:"not null;index"`
+	Score        int       `json:"score" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
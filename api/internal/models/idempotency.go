@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Idempotency key states
+const (
+	IdempotencyPending   = "pending"
+	IdempotencyCompleted = "completed"
+)
+
+// IdempotencyKey records a request made with an Idempotency-Key header so
+// that retries get the first response instead of repeating its effects.
+// Owner is the API key the key belongs to, as "key:<id>".
+type IdempotencyKey struct {
+	Owner       string `gorm:"primaryKey"`
+	Key         string `gorm:"primaryKey"`
+	Fingerprint string `gorm:"not null"`
+	Status      string `gorm:"not null;default:pending"`
+	// LockedUntil bounds how long a pending request holds the key, so one
+	// lost with its replica doesn't block retries until the key expires
+	LockedUntil         time.Time `gorm:"not null"`
+	ResponseStatus      int       `gorm:"not null;default:0"`
+	ResponseContentType string    `gorm:"not null;default:''"`
+	ResponseBody        []byte
+	ExpiresAt           time.Time `gorm:"not null;index"`
+	CreatedAt           time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+// LeaderboardEntry is a single ranked row read from the leaderboard_stats
+// materialized view
+type LeaderboardEntry struct {
+	Rank              int     `json:"rank" gorm:"-"`
+	UserID            string  `json:"user_id"`
+	Name              string  `json:"name,omitempty"`
+	SessionsCompleted int     `json:"sessions_completed"`
+	AverageScore      float64 `json:"average_score"`
+	Improvement       float64 `json:"improvement"`
+}
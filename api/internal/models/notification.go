@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	NotificationFeedbackAdded   = "feedback.added"
+	NotificationFeedbackComment = "feedback.comment"
+)
+
+type Notification struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	UserID    string     `json:"user_id" gorm:"not null;index"`
+	Type      string     `json:"type" gorm:"not null"`
+	SessionID *string    `json:"session_id,omitempty"`
+	Message   string     `json:"message" gorm:"not null"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// Organisation is a tenant, e.g. a school. Its users, sessions and private
+// prompts are invisible to other organisations.
+type Organisation struct {
+	ID        string               `json:"id" gorm:"primaryKey"`
+	Name      string               `json:"name" gorm:"not null"`
+	Settings  OrganisationSettings `json:"settings" gorm:"serializer:json;type:jsonb;not null"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// OrganisationSettings is the per-organisation configuration
+type OrganisationSettings struct {
+	// Scores at or above GoodScore are rated good, those at or above
+	// FairScore fair, and the rest poor
+	GoodScore int `json:"good_score"`
+	FairScore int `json:"fair_score"`
+}
+
+// DefaultOrganisationSettings apply to new organisations and to callers
+// outside any organisation
+var DefaultOrganisationSettings = OrganisationSettings{GoodScore: 80, FairScore: 60}
+
+const (
+	RatingGood = "good"
+	RatingFair = "fair"
+	RatingPoor = "poor"
+)
+
+// Rating places a session score against the thresholds
+func (s OrganisationSettings) Rating(score int) string {
+	switch {
+	case score >= s.GoodScore:
+		return RatingGood
+	case score >= s.FairScore:
+		return RatingFair
+	default:
+		return RatingPoor
+	}
+}
@@ -4,28 +4,56 @@ import (
 	"time"
 )
 
+// Prompt is part of the global library shared by every organisation when
+// OrganisationID is nil, and private to that organisation otherwise
 type Prompt struct {
-	ID        string    `json:"id" gorm:"primaryKey"`
-	Text      string    `json:"text" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             string    `json:"id" gorm:"primaryKey"`
+	Text           string    `json:"text" gorm:"not null"`
+	OrganisationID *string   `json:"organisation_id,omitempty" gorm:"index"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type Session struct {
-	ID           string                 `json:"id" gorm:"primaryKey"`
-	ExpectedText string                 `json:"expected_text" gorm:"not null"`
-	UserID       *string                `json:"user_id,omitempty"`
-	Transcription string                `json:"transcription" gorm:"not null"`
-	Score        int                    `json:"score" gorm:"not null"`
-	AnalysisData map[string]interface{} `json:"analysis_data" gorm:"type:jsonb"`
-	CreatedAt    time.Time              `json:"created_at"`
-	UpdatedAt    time.Time              `json:"updated_at"`
+	ID             string                 `json:"id" gorm:"primaryKey;index:idx_sessions_created_at_id,priority:2"`
+	ExpectedText   string                 `json:"expected_text" gorm:"not null"`
+	UserID         *string                `json:"user_id,omitempty" gorm:"index"`
+	OrganisationID *string                `json:"organisation_id,omitempty" gorm:"index"`
+	PromptID       *string                `json:"prompt_id,omitempty" gorm:"index"`
+	Prompt         *Prompt                `json:"prompt,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	Transcription  string                 `json:"transcription" gorm:"not null"`
+	Score          int                    `json:"score" gorm:"not null"`
+	AnalysisData   map[string]interface{} `json:"analysis_data" gorm:"type:jsonb"`
+	Notes          string                 `json:"notes" gorm:"not null;default:''"`
+	Favourite      bool                   `json:"favourite" gorm:"not null;default:false;index"`
+	AudioKey       *string                `json:"-"`
+	// Cached is set when the analysis was reused from an identical earlier
+	// submission rather than run again
+	Cached bool `json:"cached" gorm:"not null;default:false"`
+	// MLBackend and MLModel say which ML backend and model scored the
+	// session, so scores from different models aren't compared blindly.
+	// They are nil for sessions scored before backends were recorded.
+	MLBackend    *string           `json:"ml_backend" gorm:"column:ml_backend"`
+	MLModel      *string           `json:"ml_model" gorm:"column:ml_model"`
+	AnonymisedAt *time.Time        `json:"anonymised_at,omitempty" gorm:"index"`
+	Feedback     []SessionFeedback `json:"feedback,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt    time.Time         `json:"created_at" gorm:"index:idx_sessions_created_at_id,priority:1"`
+	UpdatedAt    time.Time         `json:"updated_at"`
 }
 
+const (
+	RoleLearner = "learner"
+	RoleTeacher = "teacher"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
-	ID        string    `json:"id" gorm:"primaryKey"`
-	Email     string    `json:"email" gorm:"unique;not null"`
-	Name      string    `json:"name" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                string    `json:"id" gorm:"primaryKey"`
+	Email             string    `json:"email" gorm:"unique;not null"`
+	Name              string    `json:"name" gorm:"not null"`
+	Role              string    `json:"role" gorm:"not null;default:learner"`
+	LeaderboardOptOut bool      `json:"leaderboard_opt_out" gorm:"not null;default:false"`
+	OrganisationID    *string   `json:"organisation_id,omitempty" gorm:"index"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
\ No newline at end of file
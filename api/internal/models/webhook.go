@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// Webhook event types
+const (
+	EventSessionAnalyzed     = "session.analyzed"
+	EventSessionFailed       = "session.failed"
+	EventAssignmentCompleted = "assignment.completed"
+)
+
+// WebhookEvents are the events that can be subscribed to
+var WebhookEvents = []string{EventSessionAnalyzed, EventSessionFailed, EventAssignmentCompleted}
+
+// WebhookSubscription sends an organisation's events of the given types to
+// URL, signed with Secret
+type WebhookSubscription struct {
+	ID             string    `json:"id" gorm:"primaryKey"`
+	OrganisationID string    `json:"organisation_id" gorm:"not null;index"`
+	URL            string    `json:"url" gorm:"not null"`
+	Secret         string    `json:"-" gorm:"not null"`
+	Events         []string  `json:"events" gorm:"serializer:json;type:jsonb;not null"`
+	CreatedBy      string    `json:"created_by" gorm:"not null"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// HasEvent reports whether the subscription wants event
+func (s *WebhookSubscription) HasEvent(event string) bool {
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event on its way to one subscription. Rows are
+// written in the same transaction as the change they announce, so the
+// table is both the outbox the delivery worker drains and the delivery
+// log.
+type WebhookDelivery struct {
+	ID             string               `json:"id" gorm:"primaryKey"`
+	SubscriptionID string               `json:"subscription_id" gorm:"not null;index"`
+	Subscription   *WebhookSubscription `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	EventID        string               `json:"event_id" gorm:"not null"`
+	Event          string               `json:"event" gorm:"not null"`
+	Payload        string               `json:"payload" gorm:"type:jsonb;not null"`
+	Status         string               `json:"status" gorm:"not null;default:pending"`
+	Attempts       int                  `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time            `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time           `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int                 `json:"response_status,omitempty"`
+	LastError      string               `json:"last_error,omitempty" gorm:"not null;default:''"`
+	DeliveredAt    *time.Time           `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+}
@@ -0,0 +1,116 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// channel is the LISTEN/NOTIFY channel events travel on
+const channel = "analysis_progress"
+
+// relistenDelay is how long the listener waits after losing its connection
+const relistenDelay = 5 * time.Second
+
+// notification is an event as sent through PostgreSQL. Origin lets each
+// replica skip the events it published itself, which it has already
+// delivered.
+type notification struct {
+	Origin string `json:"origin"`
+	Event  Event  `json:"event"`
+}
+
+// PostgresRelay passes events between replicas with LISTEN/NOTIFY, so a
+// watcher may be connected to a different replica from the analysis
+type PostgresRelay struct {
+	db     *gorm.DB
+	origin string
+}
+
+func NewPostgresRelay(db *gorm.DB) *PostgresRelay {
+	return &PostgresRelay{db: db, origin: uuid.New().String()}
+}
+
+func (r *PostgresRelay) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(notification{Origin: r.origin, Event: event})
+	if err != nil {
+		return fmt.Errorf("failed to encode progress event: %w", err)
+	}
+	if err := r.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", channel, string(payload)).Error; err != nil {
+		return fmt.Errorf("failed to notify progress event: %w", err)
+	}
+	return nil
+}
+
+// Listen delivers other replicas' events to bus until ctx is done,
+// reconnecting whenever the connection is lost. Events sent while it is
+// reconnecting are missed.
+func (r *PostgresRelay) Listen(ctx context.Context, bus *Bus) {
+	for {
+		err := r.listen(ctx, bus)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("analysis progress listener stopped, retrying", "error", err, "delay", relistenDelay.String())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(relistenDelay):
+		}
+	}
+}
+
+func (r *PostgresRelay) listen(ctx context.Context, bus *Bus) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	// LISTEN belongs to a connection, so one is taken from the pool for good
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("database driver is not pgx")
+		}
+		pgConn := stdlibConn.Conn()
+
+		if _, err := pgConn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+		defer func() {
+			// Don't hand a listening connection back to the pool
+			if !pgConn.IsClosed() {
+				pgConn.Exec(context.Background(), "UNLISTEN "+channel)
+			}
+		}()
+
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+
+			var msg notification
+			if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+				slog.Warn("ignoring malformed analysis progress notification", "error", err)
+				continue
+			}
+			if msg.Origin != r.origin {
+				bus.Deliver(msg.Event)
+			}
+		}
+	})
+}
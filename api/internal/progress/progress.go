@@ -0,0 +1,155 @@
+// Package progress carries the stages of in-flight analyses to whoever is
+// watching them, on this replica or, through a Relay, on any other.
+package progress
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Stages of an analysis, in order. Saved and failed are final.
+const (
+	StageReceived    = "received"
+	StageValidated   = "validated"
+	StageSentToML    = "sent_to_ml"
+	StageTranscribed = "transcribed"
+	StageScored      = "scored"
+	StageSaved       = "saved"
+	StageFailed      = "failed"
+)
+
+const (
+	// subscriberBuffer is how many events a slow watcher may fall behind
+	// before it misses some
+	subscriberBuffer = 16
+	// retainLatest is how long the latest event of an analysis is kept for
+	// watchers that arrive late
+	retainLatest = 5 * time.Minute
+)
+
+// Event is an analysis reaching a stage
+type Event struct {
+	SessionID      string    `json:"session_id"`
+	OrganisationID string    `json:"organisation_id,omitempty"`
+	UserID         string    `json:"user_id,omitempty"`
+	Stage          string    `json:"stage"`
+	At             time.Time `json:"at"`
+	Transcription  string    `json:"transcription,omitempty"`
+	Score          *int      `json:"score,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Final reports whether no further events follow this one
+func (e Event) Final() bool {
+	return e.Stage == StageSaved || e.Stage == StageFailed
+}
+
+// Relay passes events to the other replicas, which hand them to their own
+// Bus with Deliver
+type Relay interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Bus fans events out to the watchers of each session
+type Bus struct {
+	relay Relay
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+	latest      map[string]Event
+	now         func() time.Time
+}
+
+// NewBus creates a bus. relay may be nil when there is a single replica.
+func NewBus(relay Relay) *Bus {
+	return &Bus{
+		relay:       relay,
+		subscribers: map[string]map[chan Event]struct{}{},
+		latest:      map[string]Event{},
+		now:         time.Now,
+	}
+}
+
+// Publish delivers event here and relays it to the other replicas. A nil
+// Bus publishes nothing, so callers needn't check.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	if b == nil {
+		return
+	}
+	if event.At.IsZero() {
+		event.At = b.now()
+	}
+
+	b.Deliver(event)
+	if b.relay != nil {
+		if err := b.relay.Publish(ctx, event); err != nil {
+			slog.WarnContext(ctx, "failed to relay analysis progress", "session_id", event.SessionID, "error", err)
+		}
+	}
+}
+
+// Deliver hands event to this replica's watchers only
+func (b *Bus) Deliver(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune()
+	b.latest[event.SessionID] = event
+
+	for ch := range b.subscribers[event.SessionID] {
+		select {
+		case ch <- event:
+		default:
+			// The watcher isn't keeping up; it still learns the outcome
+			// from Latest when it asks
+		}
+	}
+}
+
+// Subscribe watches a session until cancel is called
+func (b *Bus) Subscribe(sessionID string) (events <-chan Event, cancel func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[sessionID] == nil {
+		b.subscribers[sessionID] = map[chan Event]struct{}{}
+	}
+	b.subscribers[sessionID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers[sessionID], ch)
+			if len(b.subscribers[sessionID]) == 0 {
+				delete(b.subscribers, sessionID)
+			}
+		})
+	}
+}
+
+// Latest is the most recent event of a session seen lately, if any
+func (b *Bus) Latest(sessionID string) (Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event, ok := b.latest[sessionID]
+	if ok && b.now().Sub(event.At) > retainLatest {
+		return Event{}, false
+	}
+	return event, ok
+}
+
+// prune forgets analyses that haven't moved lately. Callers hold mu.
+func (b *Bus) prune() {
+	cutoff := b.now().Add(-retainLatest)
+	for id, event := range b.latest {
+		if event.At.Before(cutoff) {
+			delete(b.latest, id)
+		}
+	}
+}
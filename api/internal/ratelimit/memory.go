@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// pruneEvery is how many takes pass between sweeps for idle buckets
+const pruneEvery = 1000
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	window  time.Duration
+}
+
+// MemoryStore keeps buckets in process memory, so each replica enforces
+// its own limits
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.takes++
+	if s.takes%pruneEvery == 0 {
+		s.prune(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	b.window = limit.Window()
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return newResult(limit, b.tokens, allowed), nil
+}
+
+// prune drops buckets that have refilled completely, since a new bucket
+// behaves the same
+func (s *MemoryStore) prune(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > b.window {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// takeSQL refills and takes from a bucket in one statement, so concurrent
+// requests on different replicas can't both spend the last token
+const takeSQL = `
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES (@key, CAST(@burst AS double precision) - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+	tokens = CASE
+		WHEN ` + refilled + ` >= 1 THEN ` + refilled + ` - 1
+		ELSE ` + refilled + `
+	END,
+	allowed = ` + refilled + ` >= 1,
+	updated_at = now()
+RETURNING tokens, allowed`
+
+const refilled = `LEAST(CAST(@burst AS double precision),
+	b.tokens + GREATEST(0, CAST(EXTRACT(EPOCH FROM now() - b.updated_at) AS double precision)) * CAST(@rate AS double precision))`
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so every
+// replica draws from the same budget
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	var row struct {
+		Tokens  float64
+		Allowed bool
+	}
+	err := s.db.WithContext(ctx).Raw(takeSQL, map[string]interface{}{
+		"key":   key,
+		"burst": limit.Burst,
+		"rate":  limit.Rate,
+	}).Scan(&row).Error
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+
+	return newResult(limit, row.Tokens, row.Allowed), nil
+}
+
+// RunPruner prunes buckets idle for longer than idle every interval until
+// ctx is done
+func (s *PostgresStore) RunPruner(ctx context.Context, interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Prune(ctx, idle); err != nil && ctx.Err() == nil {
+				slog.Warn("rate limit bucket pruning failed", "error", err)
+			}
+		}
+	}
+}
+
+// Prune deletes buckets untouched for longer than idle; any such bucket
+// has refilled and would be recreated full
+func (s *PostgresStore) Prune(ctx context.Context, idle time.Duration) (int64, error) {
+	result := s.db.WithContext(ctx).Exec("DELETE FROM rate_limit_buckets WHERE updated_at < ?", time.Now().Add(-idle))
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to prune rate limit buckets: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
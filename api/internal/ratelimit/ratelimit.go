@@ -0,0 +1,137 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable
+// bucket stores: in memory for a single instance, or PostgreSQL so that
+// replicas share one budget.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests may be made at once, and tokens
+// refill at Rate per second up to Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Window is how long an empty bucket takes to refill completely
+func (l Limit) Window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining whole tokens after this request
+	Remaining int
+	// RetryAfter is how long until a token is available, when not Allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// newResult derives a Result from the tokens left in a bucket after a take
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(0, s) * float64(time.Second))
+}
+
+// Store keeps token buckets. Take refills the bucket for key according to
+// limit, then removes one token if one is available.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter applies named policies, e.g. "analyze", to caller identities
+type Limiter struct {
+	store    Store
+	policies map[string]Limit
+}
+
+func NewLimiter(store Store, policies map[string]Limit) *Limiter {
+	return &Limiter{store: store, policies: policies}
+}
+
+// Policy returns the configured limit for policy, if there is one
+func (l *Limiter) Policy(name string) (Limit, bool) {
+	limit, ok := l.policies[name]
+	return limit, ok
+}
+
+// Take takes a token from identity's bucket for policy. limit is normally
+// the policy's own, but callers may substitute an override, e.g. an API
+// key's.
+func (l *Limiter) Take(ctx context.Context, policy, identity string, limit Limit) (Result, error) {
+	return l.store.Take(ctx, policy+":"+identity, limit)
+}
+
+// MaxWindow is the longest any configured bucket takes to refill; buckets
+// idle for longer are full and can be dropped
+func (l *Limiter) MaxWindow() time.Duration {
+	var max time.Duration
+	for _, limit := range l.policies {
+		if w := limit.Window(); w > max {
+			max = w
+		}
+	}
+	return max
+}
+
+var units = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParsePolicies reads a comma-separated list of name=count/unit[:burst],
+// e.g. "default=300/m:100,analyze=10/m:5". The burst defaults to count.
+// An empty spec or "off" disables rate limiting.
+func ParsePolicies(spec string) (map[string]Limit, error) {
+	policies := map[string]Limit{}
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		return policies, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid rate limit %q: expected name=count/unit[:burst]", entry)
+		}
+
+		rate, burstStr, hasBurst := strings.Cut(value, ":")
+		countStr, unit, ok := strings.Cut(rate, "/")
+		count, err := strconv.Atoi(countStr)
+		if !ok || err != nil || count <= 0 || units[unit] == 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: rate must look like 10/m (units s, m, h)", entry)
+		}
+
+		limit := Limit{Rate: float64(count) / units[unit].Seconds(), Burst: count}
+		if hasBurst {
+			if limit.Burst, err = strconv.Atoi(burstStr); err != nil || limit.Burst <= 0 {
+				return nil, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", entry)
+			}
+		}
+		policies[name] = limit
+	}
+
+	return policies, nil
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]Limit
+		wantErr bool
+	}{
+		{spec: "", want: map[string]Limit{}},
+		{spec: "off", want: map[string]Limit{}},
+		{spec: "default=60/m", want: map[string]Limit{"default": {Rate: 1, Burst: 60}}},
+		{
+			spec: "default=300/m:100, analyze=10/s:5",
+			want: map[string]Limit{"default": {Rate: 5, Burst: 100}, "analyze": {Rate: 10, Burst: 5}},
+		},
+		{spec: "default=3600/h:1", want: map[string]Limit{"default": {Rate: 1, Burst: 1}}},
+		{spec: "default", wantErr: true},
+		{spec: "=10/m", wantErr: true},
+		{spec: "default=10", wantErr: true},
+		{spec: "default=10/d", wantErr: true},
+		{spec: "default=0/m", wantErr: true},
+		{spec: "default=10/m:0", wantErr: true},
+		{spec: "default=10/m:many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParsePolicies(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePolicies(%q) = %v, want an error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParsePolicies(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			for name, limit := range tt.want {
+				if got[name] != limit {
+					t.Errorf("policy %s = %+v, want %+v", name, got[name], limit)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 2}
+	start := time.Unix(0, 0)
+
+	// Each step takes one token at the given offset from start
+	tests := []struct {
+		name       string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{"full bucket", 0, true, 1, 0},
+		{"last token", 0, true, 0, 0},
+		{"empty bucket", 0, false, 0, time.Second},
+		{"half refilled", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"one token refilled", 1500 * time.Millisecond, true, 0, 0},
+		{"refill stops at burst", time.Hour, true, 1, 0},
+	}
+
+	store := NewMemoryStore()
+	for _, tt := range tests {
+		store.now = func() time.Time { return start.Add(tt.at) }
+		result, err := store.Take(context.Background(), "caller", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != tt.allowed || result.Remaining != tt.remaining || result.RetryAfter != tt.retryAfter {
+			t.Errorf("%s: got allowed=%v remaining=%d retry after %s, want allowed=%v remaining=%d retry after %s",
+				tt.name, result.Allowed, result.Remaining, result.RetryAfter, tt.allowed, tt.remaining, tt.retryAfter)
+		}
+	}
+}
+
+func TestMemoryStoreKeepsBucketsApart(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 1}
+
+	if result, _ := store.Take(context.Background(), "a", limit); !result.Allowed {
+		t.Fatal("first take from a was refused")
+	}
+	if result, _ := store.Take(context.Background(), "b", limit); !result.Allowed {
+		t.Error("b was limited by a's bucket")
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"speaktrainer-api/internal/metrics"
+	"speaktrainer-api/internal/models"
+)
+
+// AnalysisCache remembers ML analyses by a hash of the audio, the expected
+// text and the model, so resubmitting the same recording costs a
+// lookup rather than a model run. The most recently used entries are also
+// kept in memory; the rest are shared by all replicas through the database.
+// A nil cache caches nothing.
+//
+// Entries hold a learner's transcription, so each records the session and
+// user it was made for and is erased with them. Other replicas may keep
+// an erased entry in memory until it expires or is pushed out.
+type AnalysisCache struct {
+	db   *gorm.DB
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// recency holds *cachedAnalysis, most recently used first
+	recency *list.List
+}
+
+type cachedAnalysis struct {
+	key      string
+	response *AnalysisResponse
+	backend  string
+	source   AnalysisSource
+	created  time.Time
+}
+
+// AnalysisSource is the session an analysis was made for
+type AnalysisSource struct {
+	SessionID string
+	UserID    *string
+}
+
+// NewAnalysisCache creates a cache whose entries live for ttl, keeping up
+// to size of them in memory
+func NewAnalysisCache(db *gorm.DB, size int, ttl time.Duration) *AnalysisCache {
+	return &AnalysisCache{
+		db:      db,
+		ttl:     ttl,
+		size:    size,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		recency: list.New(),
+	}
+}
+
+// key identifies an analysis. Each part is length-prefixed so that moving
+// bytes from the text to the audio can't produce the same hash.
+func (c *AnalysisCache) key(model string, audio []byte, expectedText string) string {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(model), []byte(expectedText), audio} {
+		binary.Write(h, binary.BigEndian, uint64(len(part)))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns model's cached analysis of the audio for the text, if any,
+// with the backend that ran it. The response is shared and must not be
+// modified.
+func (c *AnalysisCache) Get(ctx context.Context, model string, audio []byte, expectedText string) (*AnalysisResponse, string, bool) {
+	if c == nil {
+		return nil, "", false
+	}
+	key := c.key(model, audio, expectedText)
+
+	if entry, ok := c.getMemory(key); ok {
+		metrics.ObserveAnalysisCache("memory")
+		return entry.response, entry.backend, true
+	}
+
+	var entry models.AnalysisCacheEntry
+	err := c.db.WithContext(ctx).Where("key = ? AND created_at > ?", key, c.now().Add(-c.ttl)).First(&entry).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			// A cache that can't be read is a miss, not a failed analysis
+			slog.WarnContext(ctx, "failed to read analysis cache", "error", err)
+		}
+		metrics.ObserveAnalysisCache("miss")
+		return nil, "", false
+	}
+
+	var response AnalysisResponse
+	if err := json.Unmarshal([]byte(entry.Response), &response); err != nil {
+		slog.WarnContext(ctx, "ignoring unreadable analysis cache entry", "key", key, "error", err)
+		metrics.ObserveAnalysisCache("miss")
+		return nil, "", false
+	}
+
+	source := AnalysisSource{UserID: entry.UserID}
+	if entry.SessionID != nil {
+		source.SessionID = *entry.SessionID
+	}
+	c.putMemory(&cachedAnalysis{key: key, response: &response, backend: entry.Backend, source: source, created: entry.CreatedAt})
+	metrics.ObserveAnalysisCache("database")
+	return &response, entry.Backend, true
+}
+
+// Put caches an analysis of the audio for the text, run by model on
+// backend for source. Failing to do so only costs a later model run, so it
+// is logged rather than returned.
+func (c *AnalysisCache) Put(ctx context.Context, source AnalysisSource, model, backend string, audio []byte, expectedText string, response *AnalysisResponse) {
+	if c == nil {
+		return
+	}
+	key := c.key(model, audio, expectedText)
+	now := c.now()
+
+	c.putMemory(&cachedAnalysis{key: key, response: response, backend: backend, source: source, created: now})
+
+	payload, err := json.Marshal(response)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to encode analysis for the cache", "error", err)
+		return
+	}
+	err = c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"response", "backend", "session_id", "user_id", "created_at"}),
+	}).Create(&models.AnalysisCacheEntry{
+		Key:       key,
+		Response:  string(payload),
+		Backend:   backend,
+		SessionID: &source.SessionID,
+		UserID:    source.UserID,
+		CreatedAt: now,
+	}).Error
+	if err != nil {
+		slog.WarnContext(ctx, "failed to write analysis cache", "error", err)
+	}
+}
+
+func (c *AnalysisCache) getMemory(key string) (*cachedAnalysis, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cachedAnalysis)
+	if c.now().Sub(entry.created) >= c.ttl {
+		c.recency.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.recency.MoveToFront(el)
+	return entry, true
+}
+
+func (c *AnalysisCache) putMemory(entry *cachedAnalysis) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[entry.key]; ok {
+		el.Value = entry
+		c.recency.MoveToFront(el)
+		return
+	}
+
+	c.entries[entry.key] = c.recency.PushFront(entry)
+	for c.recency.Len() > c.size {
+		oldest := c.recency.Back()
+		c.recency.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedAnalysis).key)
+	}
+}
+
+// ForgetUser drops the entries made for userID's sessions from memory. The
+// caller deletes them from the database, along with the sessions.
+func (c *AnalysisCache) ForgetUser(userID string) {
+	c.forget(func(source AnalysisSource) bool {
+		return source.UserID != nil && *source.UserID == userID
+	})
+}
+
+// ForgetSessions drops the entries made for sessions from memory
+func (c *AnalysisCache) ForgetSessions(sessionIDs []string) {
+	ids := make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		ids[id] = true
+	}
+	c.forget(func(source AnalysisSource) bool { return ids[source.SessionID] })
+}
+
+func (c *AnalysisCache) forget(match func(AnalysisSource) bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.entries {
+		if match(el.Value.(*cachedAnalysis).source) {
+			c.recency.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// RunPruner deletes expired entries every interval until ctx is done
+func (c *AnalysisCache) RunPruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Prune(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("analysis cache pruning failed", "error", err)
+			}
+		}
+	}
+}
+
+// Prune deletes entries older than the TTL from the database; expired
+// entries in memory are dropped when next looked up or pushed out
+func (c *AnalysisCache) Prune(ctx context.Context) (int64, error) {
+	result := c.db.WithContext(ctx).Where("created_at <= ?", c.now().Add(-c.ttl)).Delete(&models.AnalysisCacheEntry{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to prune analysis cache: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
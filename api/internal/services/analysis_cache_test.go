@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"speaktrainer-api/internal/dbtest"
+)
+
+func TestAnalysisCacheForget(t *testing.T) {
+	alice, bob := "alice", "bob"
+	tests := []struct {
+		name   string
+		forget func(c *AnalysisCache)
+		kept   []string
+	}{
+		{"user", func(c *AnalysisCache) { c.ForgetUser(alice) }, []string{"session-b"}},
+		{"sessions", func(c *AnalysisCache) { c.ForgetSessions([]string{"session-b"}) }, []string{"session-a1", "session-a2"}},
+		{"unknown user", func(c *AnalysisCache) { c.ForgetUser("carol") }, []string{"session-a1", "session-a2", "session-b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			cache := NewAnalysisCache(db, 10, time.Hour)
+
+			sources := []AnalysisSource{
+				{SessionID: "session-a1", UserID: &alice},
+				{SessionID: "session-a2", UserID: &alice},
+				{SessionID: "session-b", UserID: &bob},
+			}
+			for _, source := range sources {
+				mock.ExpectExec(`INSERT INTO "analysis_cache_entries"`).WillReturnResult(sqlmock.NewResult(0, 1))
+				cache.Put(context.Background(), source, "model", "backend", []byte(source.SessionID), "text", &AnalysisResponse{})
+			}
+
+			tt.forget(cache)
+
+			kept := map[string]bool{}
+			for _, id := range tt.kept {
+				kept[id] = true
+			}
+			for _, source := range sources {
+				key := cache.key("model", []byte(source.SessionID), "text")
+				if _, ok := cache.getMemory(key); ok != kept[source.SessionID] {
+					t.Errorf("%s in memory = %v, want %v", source.SessionID, ok, kept[source.SessionID])
+				}
+			}
+		})
+	}
+}
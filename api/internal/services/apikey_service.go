@@ -0,0 +1,241 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/ratelimit"
+)
+
+// apiKeyPrefix starts every key so leaked keys are easy to recognise
+const apiKeyPrefix = "stk_"
+
+// lastUsedResolution limits how often last_used_at is written for a busy key
+const lastUsedResolution = time.Minute
+
+var (
+	ErrAPIKeyNotFound = kindError(ErrNotFound, "API key not found")
+	ErrInvalidAPIKey  = kindError(ErrInvalidInput, "invalid API key")
+)
+
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+type CreateAPIKeyRequest struct {
+	Tenant         Tenant
+	Name           string
+	Scopes         []string
+	UserID         *string
+	OrganisationID *string
+	RateLimits     string
+	ExpiresAt      *time.Time
+	CreatedBy      string
+}
+
+// CreateAPIKey stores a new key and returns it with its plaintext secret,
+// which can't be recovered later
+func (s *APIKeyService) CreateAPIKey(req CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+	if (req.UserID == nil) == (req.OrganisationID == nil) {
+		return nil, "", fmt.Errorf("%w: exactly one of user_id and organisation_id is required", ErrInvalidAPIKey)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	for _, scope := range req.Scopes {
+		if !isValidScope(scope) {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKey, scope)
+		}
+	}
+	if _, err := ratelimit.ParsePolicies(req.RateLimits); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKey)
+	}
+
+	// Admins inside an organisation can only issue keys for it or its users
+	if req.UserID != nil {
+		var count int64
+		query := s.db.Model(&models.User{}).Where("id = ?", *req.UserID)
+		if req.Tenant.OrganisationID != "" {
+			query = query.Scopes(ownedBy(req.Tenant, "users"))
+		}
+		if err := query.Count(&count).Error; err != nil {
+			return nil, "", fmt.Errorf("failed to fetch user: %w", err)
+		}
+		if count == 0 {
+			return nil, "", ErrUserNotFound
+		}
+	}
+	if req.OrganisationID != nil {
+		if req.Tenant.OrganisationID != "" && req.Tenant.OrganisationID != *req.OrganisationID {
+			return nil, "", ErrOrganisationNotFound
+		}
+		var count int64
+		if err := s.db.Model(&models.Organisation{}).Where("id = ?", *req.OrganisationID).Count(&count).Error; err != nil {
+			return nil, "", fmt.Errorf("failed to fetch organisation: %w", err)
+		}
+		if count == 0 {
+			return nil, "", ErrOrganisationNotFound
+		}
+	}
+
+	prefix, secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.APIKey{
+		ID:             uuid.New().String(),
+		Name:           strings.TrimSpace(req.Name),
+		Prefix:         prefix,
+		Hash:           hashAPIKey(secret),
+		Scopes:         req.Scopes,
+		UserID:         req.UserID,
+		OrganisationID: req.OrganisationID,
+		RateLimits:     req.RateLimits,
+		CreatedBy:      req.CreatedBy,
+		ExpiresAt:      req.ExpiresAt,
+	}
+
+	if err := s.db.Create(key).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return key, secret, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(tenant Tenant) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Scopes(managedKeys(tenant)).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey stops a key from authenticating. The row is kept so the
+// key's history stays visible.
+func (s *APIKeyService) RevokeAPIKey(tenant Tenant, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Scopes(managedKeys(tenant)).First(&key, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch API key: %w", err)
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := s.db.Model(&key).Update("revoked_at", now).Error; err != nil {
+			return nil, fmt.Errorf("failed to revoke API key: %w", err)
+		}
+	}
+
+	return &key, nil
+}
+
+// Authenticate returns the active key matching token and records its use.
+// It returns nil for unknown, revoked or expired keys.
+func (s *APIKeyService) Authenticate(token string) (*models.APIKey, error) {
+	prefix, ok := apiKeyPrefixOf(token)
+	if !ok {
+		return nil, nil
+	}
+
+	var key models.APIKey
+	if err := s.db.First(&key, "prefix = ?", prefix).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch API key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKey(token))) != 1 {
+		return nil, nil
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(now)) {
+		return nil, nil
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedResolution {
+		err := s.db.Model(&models.APIKey{}).
+			Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-lastUsedResolution)).
+			Update("last_used_at", now).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to record API key use: %w", err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return &key, nil
+}
+
+// managedKeys limits an API key query to the keys a tenant's admins manage:
+// every key for the zero Tenant, otherwise those owned by the organisation
+// or by its users
+func managedKeys(t Tenant) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if t.OrganisationID == "" {
+			return db
+		}
+		return db.Where("organisation_id = ? OR user_id IN (?)", t.OrganisationID,
+			db.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Select("id").Where("organisation_id = ?", t.OrganisationID))
+	}
+}
+
+func isValidScope(scope string) bool {
+	for _, s := range models.AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// generateAPIKey returns a key's lookup prefix and the full secret, which
+// looks like stk_<prefix>_<random>
+func generateAPIKey() (prefix, secret string, err error) {
+	buf := make([]byte, 30)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	prefix = hex.EncodeToString(buf[:6])
+	secret = apiKeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(buf[6:])
+	return prefix, secret, nil
+}
+
+func apiKeyPrefixOf(token string) (string, bool) {
+	rest, ok := strings.CutPrefix(token, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	prefix, _, ok := strings.Cut(rest, "_")
+	return prefix, ok && prefix != ""
+}
+
+// hashAPIKey is a plain SHA-256: keys are long random strings, so a slow
+// password hash would add latency to every request without adding safety
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package services
+
+import "errors"
+
+// Error kinds. Every error a service returns for a caller's mistake wraps
+// one of these, so handlers can map them to a status with errors.Is without
+// knowing each specific error.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrConflict     = errors.New("conflict")
+	// ErrUnavailable is a dependency being down rather than a mistake, but
+	// is still worth telling the caller apart from a bug
+	ErrUnavailable = errors.New("unavailable")
+)
+
+var (
+	ErrPromptNotFound       = kindError(ErrNotFound, "prompt not found")
+	ErrSessionNotFound      = kindError(ErrNotFound, "session not found")
+	ErrFeedbackNotFound     = kindError(ErrNotFound, "feedback not found")
+	ErrNotificationNotFound = kindError(ErrNotFound, "notification not found")
+	ErrGroupNotFound        = kindError(ErrNotFound, "group not found")
+	ErrGroupMemberNotFound  = kindError(ErrNotFound, "group member not found")
+	ErrAssignmentNotFound   = kindError(ErrNotFound, "assignment not found")
+	ErrUserNotFound         = kindError(ErrNotFound, "user not found")
+)
+
+// serviceError is a sentinel with its own message that also matches its kind
+type serviceError struct {
+	kind error
+	msg  string
+}
+
+func kindError(kind error, msg string) error {
+	return &serviceError{kind: kind, msg: msg}
+}
+
+func (e *serviceError) Error() string {
+	return e.msg
+}
+
+func (e *serviceError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+)
+
+// ErrInvalidFeedback is returned when a rubric or comment anchor doesn't
+// fit the session it is attached to
+var ErrInvalidFeedback = kindError(ErrInvalidInput, "invalid feedback")
+
+const (
+	minRubricScore = 1
+	maxRubricScore = 5
+)
+
+type FeedbackService struct {
+	db *gorm.DB
+}
+
+func NewFeedbackService(db *gorm.DB) *FeedbackService {
+	return &FeedbackService{db: db}
+}
+
+type CommentInput struct {
+	Body      string
+	StartMs   *int
+	EndMs     *int
+	WordIndex *int
+}
+
+type CreateFeedbackRequest struct {
+	SessionID string
+	TeacherID string
+	Rubric    models.Rubric
+	Summary   string
+	Comments  []CommentInput
+}
+
+// AddFeedback attaches a teacher's rubric and comments to a session and
+// notifies the learner. It returns nil when the session doesn't exist.
+func (s *FeedbackService) AddFeedback(req CreateFeedbackRequest) (*models.SessionFeedback, error) {
+	if err := validateRubric(req.Rubric); err != nil {
+		return nil, err
+	}
+
+	var feedback *models.SessionFeedback
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.First(&session, "id = ?", req.SessionID).Error; err != nil {
+			return err
+		}
+
+		feedback = &models.SessionFeedback{
+			ID:        uuid.New().String(),
+			SessionID: session.ID,
+			TeacherID: req.TeacherID,
+			Rubric:    req.Rubric,
+			Summary:   req.Summary,
+		}
+
+		for _, input := range req.Comments {
+			comment, err := buildComment(session, feedback.ID, req.TeacherID, input)
+			if err != nil {
+				return err
+			}
+			feedback.Comments = append(feedback.Comments, *comment)
+		}
+
+		if err := tx.Create(feedback).Error; err != nil {
+			return fmt.Errorf("failed to create feedback: %w", err)
+		}
+
+		if session.UserID != nil && *session.UserID != req.TeacherID {
+			message := fmt.Sprintf("Your teacher left feedback on \"%s\"", session.ExpectedText)
+			return notify(tx, *session.UserID, models.NotificationFeedbackAdded, &session.ID, message)
+		}
+		return nil
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return feedback, nil
+}
+
+// AddComment appends a comment to an existing feedback thread. The learner
+// is notified unless they wrote the comment themselves. It returns nil when
+// the feedback doesn't exist on the given session.
+func (s *FeedbackService) AddComment(sessionID, feedbackID, authorID string, input CommentInput) (*models.FeedbackComment, error) {
+	var comment *models.FeedbackComment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var feedback models.SessionFeedback
+		if err := tx.First(&feedback, "id = ? AND session_id = ?", feedbackID, sessionID).Error; err != nil {
+			return err
+		}
+
+		var session models.Session
+		if err := tx.First(&session, "id = ?", sessionID).Error; err != nil {
+			return err
+		}
+
+		var err error
+		comment, err = buildComment(session, feedback.ID, authorID, input)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(comment).Error; err != nil {
+			return fmt.Errorf("failed to create comment: %w", err)
+		}
+
+		if session.UserID != nil && *session.UserID != authorID {
+			message := fmt.Sprintf("New comment on your feedback for \"%s\"", session.ExpectedText)
+			return notify(tx, *session.UserID, models.NotificationFeedbackComment, &session.ID, message)
+		}
+		return nil
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+func validateRubric(rubric models.Rubric) error {
+	scores := []struct {
+		name  string
+		score int
+	}{
+		{"intelligibility", rubric.Intelligibility},
+		{"stress", rubric.Stress},
+		{"intonation", rubric.Intonation},
+		{"fluency", rubric.Fluency},
+	}
+	for _, s := range scores {
+		if s.score < minRubricScore || s.score > maxRubricScore {
+			return fmt.Errorf("%w: %s must be between %d and %d", ErrInvalidFeedback, s.name, minRubricScore, maxRubricScore)
+		}
+	}
+	return nil
+}
+
+// buildComment validates a comment's anchor against the session and
+// resolves a word index to the word it refers to
+func buildComment(session models.Session, feedbackID, authorID string, input CommentInput) (*models.FeedbackComment, error) {
+	if strings.TrimSpace(input.Body) == "" {
+		return nil, fmt.Errorf("%w: comment body is required", ErrInvalidFeedback)
+	}
+
+	comment := &models.FeedbackComment{
+		ID:         uuid.New().String(),
+		FeedbackID: feedbackID,
+		AuthorID:   authorID,
+		Body:       input.Body,
+		StartMs:    input.StartMs,
+		EndMs:      input.EndMs,
+		WordIndex:  input.WordIndex,
+	}
+
+	if input.StartMs != nil && *input.StartMs < 0 {
+		return nil, fmt.Errorf("%w: start_ms must not be negative", ErrInvalidFeedback)
+	}
+	if input.EndMs != nil {
+		if input.StartMs == nil {
+			return nil, fmt.Errorf("%w: end_ms requires start_ms", ErrInvalidFeedback)
+		}
+		if *input.EndMs < *input.StartMs {
+			return nil, fmt.Errorf("%w: end_ms must not be before start_ms", ErrInvalidFeedback)
+		}
+	}
+
+	if input.WordIndex != nil {
+		words := strings.Fields(session.ExpectedText)
+		if *input.WordIndex < 0 || *input.WordIndex >= len(words) {
+			return nil, fmt.Errorf("%w: word_index must be between 0 and %d", ErrInvalidFeedback, len(words)-1)
+		}
+		comment.Word = words[*input.WordIndex]
+	}
+
+	return comment, nil
+}
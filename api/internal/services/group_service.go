@@ -0,0 +1,246 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"speaktrainer-api/internal/models"
+)
+
+type GroupService struct {
+	db *gorm.DB
+}
+
+func NewGroupService(db *gorm.DB) *GroupService {
+	return &GroupService{db: db}
+}
+
+func (s *GroupService) CreateGroup(tenant Tenant, name string) (*models.Group, error) {
+	group := &models.Group{
+		ID:             uuid.New().String(),
+		OrganisationID: tenant.organisationID(),
+		Name:           name,
+	}
+
+	if err := s.db.Create(group).Error; err != nil {
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	return group, nil
+}
+
+// GetGroupByID returns the tenant's group with that ID, or nil when there is
+// none; another organisation's groups are not found
+func (s *GroupService) GetGroupByID(tenant Tenant, id string) (*models.Group, error) {
+	var group models.Group
+	if err := s.db.Preload("Members").Scopes(ownedBy(tenant, "groups")).First(&group, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch group: %w", err)
+	}
+	return &group, nil
+}
+
+// AddMember adds a user of the tenant to one of its groups. Adding an
+// existing member is a no-op.
+func (s *GroupService) AddMember(tenant Tenant, groupID, userID string) (*models.Group, error) {
+	group, err := s.GetGroupByID(tenant, groupID)
+	if err != nil || group == nil {
+		return nil, err
+	}
+
+	var count int64
+	err = s.db.Model(&models.User{}).Scopes(ownedBy(tenant, "users")).Where("id = ?", userID).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	member := &models.GroupMember{GroupID: groupID, UserID: userID}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(member).Error; err != nil {
+		return nil, fmt.Errorf("failed to add group member: %w", err)
+	}
+
+	return s.GetGroupByID(tenant, groupID)
+}
+
+func (s *GroupService) RemoveMember(tenant Tenant, groupID, userID string) error {
+	group, err := s.GetGroupByID(tenant, groupID)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return ErrGroupNotFound
+	}
+
+	result := s.db.Delete(&models.GroupMember{}, "group_id = ? AND user_id = ?", groupID, userID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove group member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrGroupMemberNotFound
+	}
+	return nil
+}
+
+type CreateAssignmentRequest struct {
+	Tenant    Tenant
+	GroupID   string
+	PromptID  string
+	DueAt     *time.Time
+	CreatedBy string
+}
+
+// CreateAssignment sets one of the tenant's groups a prompt the tenant can
+// see
+func (s *GroupService) CreateAssignment(req CreateAssignmentRequest) (*models.Assignment, error) {
+	group, err := s.GetGroupByID(req.Tenant, req.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrGroupNotFound
+	}
+
+	var count int64
+	err = s.db.Model(&models.Prompt{}).Scopes(visiblePrompts(req.Tenant)).
+		Where("id = ?", req.PromptID).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch prompt: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrPromptNotFound
+	}
+
+	assignment := &models.Assignment{
+		ID:             uuid.New().String(),
+		OrganisationID: req.Tenant.organisationID(),
+		GroupID:        req.GroupID,
+		PromptID:       req.PromptID,
+		DueAt:          req.DueAt,
+		CreatedBy:      req.CreatedBy,
+	}
+	if err := s.db.Create(assignment).Error; err != nil {
+		return nil, fmt.Errorf("failed to create assignment: %w", err)
+	}
+
+	return assignment, nil
+}
+
+// ListAssignments returns the assignments of one of the tenant's groups with
+// their prompts, the latest first
+func (s *GroupService) ListAssignments(tenant Tenant, groupID string) ([]models.Assignment, error) {
+	assignments := []models.Assignment{}
+	if err := s.db.Preload("Prompt").Scopes(ownedBy(tenant, "assignments")).Where("group_id = ?", groupID).
+		Order("created_at DESC").Order("id").Find(&assignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+// GetAssignment returns one of the tenant's assignments with who has
+// completed it, or nil when there is none
+func (s *GroupService) GetAssignment(tenant Tenant, groupID, id string) (*models.Assignment, error) {
+	var assignment models.Assignment
+	err := s.db.Preload("Prompt").Preload("Completions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at").Order("user_id")
+	}).Scopes(ownedBy(tenant, "assignments")).
+		First(&assignment, "id = ? AND group_id = ?", id, groupID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch assignment: %w", err)
+	}
+	return &assignment, nil
+}
+
+func (s *GroupService) DeleteAssignment(tenant Tenant, groupID, id string) error {
+	result := s.db.Scopes(ownedBy(tenant, "assignments")).
+		Delete(&models.Assignment{}, "id = ? AND group_id = ?", id, groupID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete assignment: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAssignmentNotFound
+	}
+	return nil
+}
+
+// AssignmentCompletedEvent is the data of assignment.completed webhooks
+type AssignmentCompletedEvent struct {
+	AssignmentID string     `json:"assignment_id"`
+	GroupID      string     `json:"group_id"`
+	PromptID     string     `json:"prompt_id"`
+	UserID       string     `json:"user_id"`
+	SessionID    string     `json:"session_id"`
+	Score        int        `json:"score"`
+	Rating       string     `json:"rating"`
+	DueAt        *time.Time `json:"due_at"`
+	CompletedAt  time.Time  `json:"completed_at"`
+}
+
+// completeAssignments records session as completing the assignments of its
+// prompt, in its user's groups, that the user hadn't completed yet, and
+// queues assignment.completed webhooks for them. Pass the transaction
+// saving the session.
+func completeAssignments(tx *gorm.DB, session *models.Session, settings models.OrganisationSettings) error {
+	if session.UserID == nil || session.PromptID == nil {
+		return nil
+	}
+	userID := *session.UserID
+
+	var tenant Tenant
+	if session.OrganisationID != nil {
+		tenant.OrganisationID = *session.OrganisationID
+	}
+
+	var assignments []models.Assignment
+	err := tx.Scopes(ownedBy(tenant, "assignments")).
+		Where("prompt_id = ?", *session.PromptID).
+		Where("group_id IN (?)", tx.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Find(&assignments).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch assignments: %w", err)
+	}
+
+	for _, assignment := range assignments {
+		completion := &models.AssignmentCompletion{
+			AssignmentID: assignment.ID,
+			UserID:       userID,
+			SessionID:    session.ID,
+			Score:        session.Score,
+			CreatedAt:    session.CreatedAt,
+		}
+		// Only the first session completes an assignment
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(completion)
+		if result.Error != nil {
+			return fmt.Errorf("failed to complete assignment: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		err := enqueueWebhooks(tx, session.OrganisationID, models.EventAssignmentCompleted, AssignmentCompletedEvent{
+			AssignmentID: assignment.ID,
+			GroupID:      assignment.GroupID,
+			PromptID:     assignment.PromptID,
+			UserID:       userID,
+			SessionID:    session.ID,
+			Score:        session.Score,
+			Rating:       settings.Rating(session.Score),
+			DueAt:        assignment.DueAt,
+			CompletedAt:  completion.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
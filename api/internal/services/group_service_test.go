@@ -0,0 +1,194 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"speaktrainer-api/internal/dbtest"
+	"speaktrainer-api/internal/models"
+)
+
+func TestAddMemberWithinTenant(t *testing.T) {
+	tenant := Tenant{OrganisationID: "org-1"}
+	tests := []struct {
+		name string
+		// groupFound and userFound say whether the group and the user
+		// belong to the tenant
+		groupFound bool
+		userFound  bool
+		wantGroup  bool
+		wantErr    error
+	}{
+		{name: "another organisation's group", wantGroup: false},
+		{name: "another organisation's user", groupFound: true, wantErr: ErrUserNotFound},
+		{name: "the tenant's group and user", groupFound: true, userFound: true, wantGroup: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			expectGroup := func() {
+				rows := sqlmock.NewRows([]string{"id", "organisation_id", "name"})
+				if tt.groupFound {
+					rows.AddRow("group-1", "org-1", "Class 1")
+				}
+				mock.ExpectQuery(`SELECT \* FROM "groups" WHERE id = \$1 AND groups\.organisation_id = \$2`).
+					WithArgs("group-1", "org-1", 1).
+					WillReturnRows(rows)
+				if tt.groupFound {
+					mock.ExpectQuery(`FROM "group_members"`).WillReturnRows(sqlmock.NewRows([]string{"group_id", "user_id"}))
+				}
+			}
+
+			expectGroup()
+			if tt.groupFound {
+				count := 0
+				if tt.userFound {
+					count = 1
+				}
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE id = \$1 AND users\.organisation_id = \$2`).
+					WithArgs("user-2", "org-1").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+			}
+			if tt.userFound {
+				mock.ExpectExec(`INSERT INTO "group_members"`).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectGroup()
+			}
+
+			group, err := NewGroupService(db).AddMember(tenant, "group-1", "user-2")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if (group != nil) != tt.wantGroup {
+				t.Errorf("group = %v, want one: %v", group, tt.wantGroup)
+			}
+		})
+	}
+}
+
+func TestLeaderboardGroupWithinTenant(t *testing.T) {
+	db, mock := dbtest.NewMock(t)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "groups" WHERE id = \$1 AND groups\.organisation_id = \$2`).
+		WithArgs("group-1", "org-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	_, err := NewLeaderboardService(db).GetLeaderboard(LeaderboardQuery{
+		Tenant:  Tenant{OrganisationID: "org-1"},
+		Period:  LeaderboardWeekly,
+		GroupID: "group-1",
+	})
+	if !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("err = %v, want %v", err, ErrGroupNotFound)
+	}
+}
+
+func TestCompleteAssignments(t *testing.T) {
+	userID, promptID, orgID := "user-1", "prompt-1", "org-1"
+	tests := []struct {
+		name    string
+		session models.Session
+		// inserted is how many rows the completion insert reports; 0 means
+		// the user had completed the assignment already
+		inserted    int64
+		wantQueries bool
+		wantWebhook bool
+	}{
+		{name: "session without a prompt", session: models.Session{ID: "s-1", UserID: &userID, OrganisationID: &orgID}},
+		{name: "anonymous session", session: models.Session{ID: "s-1", PromptID: &promptID, OrganisationID: &orgID}},
+		{
+			name:        "first completion",
+			session:     models.Session{ID: "s-1", UserID: &userID, PromptID: &promptID, OrganisationID: &orgID, Score: 80},
+			inserted:    1,
+			wantQueries: true,
+			wantWebhook: true,
+		},
+		{
+			name:        "completed already",
+			session:     models.Session{ID: "s-1", UserID: &userID, PromptID: &promptID, OrganisationID: &orgID, Score: 80},
+			wantQueries: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			if tt.wantQueries {
+				mock.ExpectQuery(`SELECT \* FROM "assignments" WHERE prompt_id = \$1 AND group_id IN \(SELECT "group_id" FROM "group_members" WHERE user_id = \$2\) AND assignments\.organisation_id = \$3`).
+					WithArgs(promptID, userID, orgID).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "group_id", "prompt_id"}).AddRow("assignment-1", "group-1", promptID))
+				mock.ExpectExec(`INSERT INTO "assignment_completions" .* ON CONFLICT DO NOTHING`).
+					WillReturnResult(sqlmock.NewResult(0, tt.inserted))
+			}
+			if tt.wantWebhook {
+				mock.ExpectQuery(`FROM "webhook_subscriptions" WHERE organisation_id = \$1`).WithArgs(orgID).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "events"}).AddRow("sub-1", `["assignment.completed"]`))
+				// id, subscription_id, event_id and event come first
+				args := []driver.Value{sqlmock.AnyArg(), "sub-1", sqlmock.AnyArg(), models.EventAssignmentCompleted}
+				for len(args) < 13 {
+					args = append(args, sqlmock.AnyArg())
+				}
+				mock.ExpectExec(`INSERT INTO "webhook_deliveries"`).WithArgs(args...).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			if err := completeAssignments(db, &tt.session, models.DefaultOrganisationSettings); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCreateAssignment(t *testing.T) {
+	tenant := Tenant{OrganisationID: "org-1"}
+	tests := []struct {
+		name        string
+		groupFound  bool
+		promptFound bool
+		wantErr     error
+	}{
+		{name: "another organisation's group", wantErr: ErrGroupNotFound},
+		{name: "another organisation's prompt", groupFound: true, wantErr: ErrPromptNotFound},
+		{name: "the tenant's group and a visible prompt", groupFound: true, promptFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			rows := sqlmock.NewRows([]string{"id", "organisation_id", "name"})
+			if tt.groupFound {
+				rows.AddRow("group-1", "org-1", "Class 1")
+			}
+			mock.ExpectQuery(`SELECT \* FROM "groups" WHERE id = \$1 AND groups\.organisation_id = \$2`).
+				WithArgs("group-1", "org-1", 1).
+				WillReturnRows(rows)
+			if tt.groupFound {
+				mock.ExpectQuery(`FROM "group_members"`).WillReturnRows(sqlmock.NewRows([]string{"group_id", "user_id"}))
+				count := 0
+				if tt.promptFound {
+					count = 1
+				}
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "prompts" WHERE id = \$1 AND \(prompts\.organisation_id IS NULL OR prompts\.organisation_id = \$2\)`).
+					WithArgs("prompt-1", "org-1").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+			}
+			if tt.promptFound {
+				mock.ExpectExec(`INSERT INTO "assignments"`).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			assignment, err := NewGroupService(db).CreateAssignment(CreateAssignmentRequest{
+				Tenant:    tenant,
+				GroupID:   "group-1",
+				PromptID:  "prompt-1",
+				CreatedBy: "teacher-1",
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (assignment.OrganisationID == nil || *assignment.OrganisationID != "org-1") {
+				t.Errorf("organisation = %v, want org-1", assignment.OrganisationID)
+			}
+		})
+	}
+}
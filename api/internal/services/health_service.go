@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+	CheckedAt    time.Time          `json:"checked_at"`
+}
+
+// HealthService checks the dependencies the API needs to serve traffic.
+// Results are cached for a short time so frequent probes don't hammer
+// PostgreSQL or the ML service.
+type HealthService struct {
+	db       *gorm.DB
+	ml       *MLPool
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu     sync.Mutex
+	cached *HealthReport
+}
+
+func NewHealthService(db *gorm.DB, ml *MLPool, timeout, cacheTTL time.Duration) *HealthService {
+	return &HealthService{
+		db:       db,
+		ml:       ml,
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Check returns the cached report if it is fresh enough, otherwise it
+// checks every dependency concurrently, each bounded by the timeout
+func (s *HealthService) Check(ctx context.Context) *HealthReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != nil && time.Since(s.cached.CheckedAt) < s.cacheTTL {
+		return s.cached
+	}
+
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"database", s.pingDatabase},
+		{"ml_service", s.pingMLService},
+	}
+
+	report := &HealthReport{
+		Status:       StatusUp,
+		Dependencies: make([]DependencyStatus, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			report.Dependencies[i] = runCheck(ctx, name, s.timeout, check)
+		}(i, c.name, c.check)
+	}
+	wg.Wait()
+
+	for _, dep := range report.Dependencies {
+		if dep.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	report.CheckedAt = time.Now()
+
+	s.cached = report
+	return report
+}
+
+func runCheck(ctx context.Context, name string, timeout time.Duration, check func(ctx context.Context) error) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := DependencyStatus{
+		Name:      name,
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func (s *HealthService) pingDatabase(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// pingMLService passes while any ML backend is up, since the pool fails
+// over to it
+func (s *HealthService) pingMLService(ctx context.Context) error {
+	return s.ml.Ping(ctx, s.timeout)
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+)
+
+var (
+	ErrIdempotencyKeyInProgress = kindError(ErrConflict, "a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused     = kindError(ErrInvalidInput, "this idempotency key was used for a different request")
+)
+
+// idempotencyLease is how long a claimed key stays locked unless renewed.
+// Running requests renew it every third of that (see Hold), so it only
+// decides how soon a retry may take over from a replica that died.
+const idempotencyLease = 2 * time.Minute
+
+// claimSQL takes a key that is new, expired, or held by a request with the
+// same fingerprint whose lease ran out. It returns no row otherwise.
+const claimSQL = `
+INSERT INTO idempotency_keys AS k (owner, key, fingerprint, status, locked_until, expires_at, created_at)
+VALUES (@owner, @key, @fingerprint, 'pending', @locked_until, @expires_at, @now)
+ON CONFLICT (owner, key) DO UPDATE SET
+	fingerprint = EXCLUDED.fingerprint,
+	status = 'pending',
+	locked_until = EXCLUDED.locked_until,
+	response_status = 0,
+	response_content_type = '',
+	response_body = NULL,
+	expires_at = EXCLUDED.expires_at,
+	created_at = EXCLUDED.created_at
+WHERE k.expires_at <= @now
+	OR (k.status = 'pending' AND k.locked_until <= @now AND k.fingerprint = EXCLUDED.fingerprint)
+RETURNING owner`
+
+type IdempotencyService struct {
+	db    *gorm.DB
+	ttl   time.Duration
+	lease time.Duration
+	now   func() time.Time
+}
+
+// NewIdempotencyService creates the service. Keys can be reused for a new
+// request once ttl has passed since they were first used.
+func NewIdempotencyService(db *gorm.DB, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{db: db, ttl: ttl, lease: idempotencyLease, now: time.Now}
+}
+
+// Begin claims owner's key for a request with the given fingerprint. It
+// returns nil when the caller should go ahead and then Complete or Release
+// the key, and the stored record when the request already completed.
+// Otherwise it returns ErrIdempotencyKeyInProgress or
+// ErrIdempotencyKeyReused.
+func (s *IdempotencyService) Begin(ctx context.Context, owner, key, fingerprint string) (*models.IdempotencyKey, error) {
+	db := s.db.WithContext(ctx)
+
+	// A key released between the claim and the lookup is claimed on the
+	// next round
+	for attempt := 0; attempt < 3; attempt++ {
+		now := s.now()
+		var claimed []string
+		err := db.Raw(claimSQL, map[string]interface{}{
+			"owner":        owner,
+			"key":          key,
+			"fingerprint":  fingerprint,
+			"locked_until": now.Add(s.lease),
+			"expires_at":   now.Add(s.ttl),
+			"now":          now,
+		}).Scan(&claimed).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+		}
+		if len(claimed) > 0 {
+			return nil, nil
+		}
+
+		var record models.IdempotencyKey
+		err = db.Where("owner = ? AND key = ?", owner, key).First(&record).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch idempotency key: %w", err)
+		}
+
+		switch {
+		case record.Fingerprint != fingerprint:
+			return nil, ErrIdempotencyKeyReused
+		case record.Status == models.IdempotencyCompleted:
+			return &record, nil
+		default:
+			return nil, ErrIdempotencyKeyInProgress
+		}
+	}
+
+	return nil, ErrIdempotencyKeyInProgress
+}
+
+// Hold renews the lease on owner's key until the returned stop is called,
+// so a retry doesn't run a request again just because it is slow, e.g. a
+// large batch. Call stop before completing or releasing the key.
+func (s *IdempotencyService) Hold(ctx context.Context, owner, key string) (stop func()) {
+	// Renewals go on if the client leaves, as the request may still finish
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := s.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+					Where("owner = ? AND key = ? AND status = ?", owner, key, models.IdempotencyPending).
+					Update("locked_until", s.now().Add(s.lease)).Error
+				if err != nil && ctx.Err() == nil {
+					slog.WarnContext(ctx, "failed to renew idempotency key", "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Complete stores the response to replay for owner's key
+func (s *IdempotencyService) Complete(ctx context.Context, owner, key string, status int, contentType string, body []byte) error {
+	err := s.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("owner = ? AND key = ?", owner, key).
+		Updates(map[string]interface{}{
+			"status":                models.IdempotencyCompleted,
+			"response_status":       status,
+			"response_content_type": contentType,
+			"response_body":         body,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees owner's key without a response, so a retry runs the
+// request again
+func (s *IdempotencyService) Release(ctx context.Context, owner, key string) error {
+	err := s.db.WithContext(ctx).
+		Where("owner = ? AND key = ? AND status = ?", owner, key, models.IdempotencyPending).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// RunPruner deletes expired keys every interval until ctx is done
+func (s *IdempotencyService) RunPruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Prune(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("idempotency key pruning failed", "error", err)
+			}
+		}
+	}
+}
+
+// Prune deletes expired keys
+func (s *IdempotencyService) Prune(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Where("expires_at <= ?", s.now()).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to prune idempotency keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"speaktrainer-api/internal/dbtest"
+	"speaktrainer-api/internal/models"
+)
+
+func TestHoldRenewsLease(t *testing.T) {
+	db, mock := dbtest.NewMock(t)
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "locked_until"=\$1 WHERE owner = \$2 AND key = \$3 AND status = \$4`).
+		WithArgs(sqlmock.AnyArg(), "key:key-1", "retry-1", models.IdempotencyPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	service := NewIdempotencyService(db, time.Hour)
+	service.lease = 30 * time.Millisecond
+
+	// The request's context ending doesn't stop the renewals; stop does
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stop := service.Hold(ctx, "key:key-1", "retry-1")
+
+	deadline := time.Now().Add(time.Second)
+	for mock.ExpectationsWereMet() != nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("lease wasn't renewed: %v", err)
+	}
+}
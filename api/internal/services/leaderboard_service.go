@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+)
+
+const (
+	LeaderboardWeekly  = "weekly"
+	LeaderboardAllTime = "all_time"
+
+	MetricSessions     = "sessions"
+	MetricAverageScore = "average_score"
+	MetricImprovement  = "improvement"
+)
+
+// Order clauses for each supported ranking metric
+var leaderboardOrder = map[string]string{
+	MetricSessions:     "ls.sessions_completed DESC, ls.average_score DESC",
+	MetricAverageScore: "ls.average_score DESC, ls.sessions_completed DESC",
+	MetricImprovement:  "ls.improvement DESC, ls.average_score DESC",
+}
+
+type LeaderboardService struct {
+	db *gorm.DB
+}
+
+func NewLeaderboardService(db *gorm.DB) *LeaderboardService {
+	return &LeaderboardService{db: db}
+}
+
+type LeaderboardQuery struct {
+	Tenant  Tenant
+	Period  string
+	Metric  string
+	GroupID string
+	Limit   int
+}
+
+func IsValidLeaderboardPeriod(period string) bool {
+	return period == LeaderboardWeekly || period == LeaderboardAllTime
+}
+
+func IsValidLeaderboardMetric(metric string) bool {
+	_, ok := leaderboardOrder[metric]
+	return ok
+}
+
+// GetLeaderboard reads ranked entries from the leaderboard_stats view,
+// ranking only users of the query's tenant. Users who opted out are
+// filtered at query time so an opt-out takes effect immediately rather
+// than on the next refresh. A group filter must name one of the tenant's
+// groups.
+func (s *LeaderboardService) GetLeaderboard(q LeaderboardQuery) ([]models.LeaderboardEntry, error) {
+	if q.GroupID != "" {
+		var count int64
+		err := s.db.Model(&models.Group{}).Scopes(ownedBy(q.Tenant, "groups")).
+			Where("id = ?", q.GroupID).Count(&count).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch group: %w", err)
+		}
+		if count == 0 {
+			return nil, ErrGroupNotFound
+		}
+	}
+
+	query := s.db.Table("leaderboard_stats AS ls").
+		Select("ls.user_id, COALESCE(u.name, '') AS name, ls.sessions_completed, ls.average_score, ls.improvement").
+		Joins("LEFT JOIN users u ON u.id = ls.user_id").
+		Where("ls.period = ?", q.Period).
+		Where("COALESCE(u.leaderboard_opt_out, false) = false").
+		Scopes(ownedBy(q.Tenant, "u")).
+		Order(leaderboardOrder[q.Metric]).
+		Order("ls.user_id")
+
+	if q.GroupID != "" {
+		query = query.Where("ls.user_id IN (?)",
+			s.db.Model(&models.GroupMember{}).Select("user_id").Where("group_id = ?", q.GroupID))
+	}
+
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var entries []models.LeaderboardEntry
+	if err := query.Scan(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch leaderboard: %w", err)
+	}
+
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+
+	return entries, nil
+}
+
+// SetOptOut updates a user's leaderboard privacy preference
+func (s *LeaderboardService) SetOptOut(tenant Tenant, userID string, optOut bool) (*models.User, error) {
+	var user models.User
+	if err := s.db.Scopes(ownedBy(tenant, "users")).First(&user, "id = ?", userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	user.LeaderboardOptOut = optOut
+	if err := s.db.Save(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// Refresh recomputes the leaderboard aggregates without blocking readers
+func (s *LeaderboardService) Refresh() error {
+	if err := s.db.Exec("REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboard_stats").Error; err != nil {
+		return fmt.Errorf("failed to refresh leaderboard: %w", err)
+	}
+	return nil
+}
+
+// RunRefresher refreshes the leaderboard every interval until ctx is done
+func (s *LeaderboardService) RunRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil {
+				slog.Warn("leaderboard refresh failed", "error", err)
+			}
+		}
+	}
+}
@@ -2,15 +2,22 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"speaktrainer-api/internal/logging"
+	"speaktrainer-api/internal/metrics"
 )
 
 type MLClient struct {
+	// Name identifies the backend in metrics
+	Name       string
 	BaseURL    string
 	HTTPClient *http.Client
 }
@@ -34,16 +41,38 @@ type TranscriptionResponse struct {
 	Transcription string `json:"transcription"`
 }
 
-func NewMLClient(baseURL string) *MLClient {
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Service string `json:"service"`
+}
+
+// MLStatusError is a non-200 response from the ML service
+type MLStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *MLStatusError) Error() string {
+	return fmt.Sprintf("ML service returned error %d: %s", e.StatusCode, e.Body)
+}
+
+func NewMLClient(name, baseURL string) *MLClient {
 	return &MLClient{
+		Name:    name,
 		BaseURL: baseURL,
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
+			// Records a client span per call and sends the W3C traceparent
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return "ml " + r.Method + " " + r.URL.Path
+				}),
+			),
 		},
 	}
 }
 
-func (c *MLClient) AnalyzePronunciation(req AnalysisRequest) (*AnalysisResponse, error) {
+func (c *MLClient) AnalyzePronunciation(ctx context.Context, req AnalysisRequest) (*AnalysisResponse, error) {
 	// Create multipart form
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
@@ -69,7 +98,7 @@ func (c *MLClient) AnalyzePronunciation(req AnalysisRequest) (*AnalysisResponse,
 
 	// Create HTTP request
 	url := fmt.Sprintf("%s/analyze", c.BaseURL)
-	httpReq, err := http.NewRequest("POST", url, &buf)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, &buf)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -77,20 +106,9 @@ func (c *MLClient) AnalyzePronunciation(req AnalysisRequest) (*AnalysisResponse,
 	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
 
 	// Make request
-	resp, err := c.HTTPClient.Do(httpReq)
+	body, err := c.do(httpReq, "analyze")
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request to ML service: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ML service returned error %d: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
 
 	// Parse response
@@ -102,7 +120,7 @@ func (c *MLClient) AnalyzePronunciation(req AnalysisRequest) (*AnalysisResponse,
 	return &analysisResp, nil
 }
 
-func (c *MLClient) Transcribe(audioData []byte, filename string) (*TranscriptionResponse, error) {
+func (c *MLClient) Transcribe(ctx context.Context, audioData []byte, filename string) (*TranscriptionResponse, error) {
 	// Create multipart form
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
@@ -123,7 +141,7 @@ func (c *MLClient) Transcribe(audioData []byte, filename string) (*Transcription
 
 	// Create HTTP request
 	url := fmt.Sprintf("%s/transcribe", c.BaseURL)
-	httpReq, err := http.NewRequest("POST", url, &buf)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, &buf)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -131,27 +149,65 @@ func (c *MLClient) Transcribe(audioData []byte, filename string) (*Transcription
 	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
 
 	// Make request
+	body, err := c.do(httpReq, "transcribe")
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse response
+	var transcriptionResp TranscriptionResponse
+	if err := json.Unmarshal(body, &transcriptionResp); err != nil {
+		return nil, fmt.Errorf("failed to parse ML service response: %w", err)
+	}
+
+	return &transcriptionResp, nil
+}
+
+func (c *MLClient) Health(ctx context.Context) (*HealthResponse, error) {
+	url := fmt.Sprintf("%s/health", c.BaseURL)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Make request
+	body, err := c.do(httpReq, "health")
+	if err != nil {
+		return nil, err
+	}
+
+	var healthResp HealthResponse
+	if err := json.Unmarshal(body, &healthResp); err != nil {
+		return nil, fmt.Errorf("failed to parse ML service response: %w", err)
+	}
+
+	return &healthResp, nil
+}
+
+// do sends a request to the ML service, forwarding the caller's request ID,
+// records its latency and outcome, and returns the body of a successful response
+func (c *MLClient) do(httpReq *http.Request, endpoint string) ([]byte, error) {
+	if id := logging.RequestID(httpReq.Context()); id != "" {
+		httpReq.Header.Set(logging.RequestIDHeader, id)
+	}
+
+	start := time.Now()
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
+		metrics.ObserveMLCall(c.Name, endpoint, 0, time.Since(start), true)
 		return nil, fmt.Errorf("failed to make request to ML service: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Read response
 	body, err := io.ReadAll(resp.Body)
+	metrics.ObserveMLCall(c.Name, endpoint, resp.StatusCode, time.Since(start), err != nil || resp.StatusCode != http.StatusOK)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ML service returned error %d: %s", resp.StatusCode, string(body))
+		return nil, &MLStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
-	// Parse response
-	var transcriptionResp TranscriptionResponse
-	if err := json.Unmarshal(body, &transcriptionResp); err != nil {
-		return nil, fmt.Errorf("failed to parse ML service response: %w", err)
-	}
-
-	return &transcriptionResp, nil
-}
\ No newline at end of file
+	return body, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Model classes a request can ask for
+const (
+	ModelFast     = "fast"
+	ModelAccurate = "accurate"
+)
+
+var (
+	// ErrInvalidModel rejects a model class other than fast or accurate
+	ErrInvalidModel = kindError(ErrInvalidInput, "model must be fast or accurate")
+	// ErrMLUnavailable is returned when no backend of the class asked for
+	// is configured, or none of them could serve the request
+	ErrMLUnavailable = kindError(ErrUnavailable, "no ML backend is available")
+)
+
+// MLBackendConfig describes one ML service of a pool
+type MLBackendConfig struct {
+	Name string
+	URL  string
+	// Class is fast or accurate, or empty for a backend that serves both
+	Class string
+	// Model is recorded on the sessions the backend scores and keys its
+	// cached analyses, so change it whenever the backend's model changes
+	Model  string
+	Weight int
+}
+
+// ParseMLBackends reads a comma-separated list of
+// name=url|class|model[|weight], e.g.
+// "large=http://ml-large:8001|accurate|whisper-large-v3,small=http://ml-small:8001|fast|whisper-base|3".
+// The class may be left empty, as in "any=http://ml:8001||whisper-base",
+// for a backend that serves both. The weight defaults to 1.
+func ParseMLBackends(spec string) ([]MLBackendConfig, error) {
+	var backends []MLBackendConfig
+	names := map[string]bool{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		name, value, ok := strings.Cut(entry, "=")
+		fields := strings.Split(value, "|")
+		if !ok || name == "" || len(fields) < 3 || len(fields) > 4 || fields[0] == "" || fields[2] == "" {
+			return nil, fmt.Errorf("invalid ML backend %q: expected name=url|class|model[|weight]", entry)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("invalid ML backend %q: %s is named twice", entry, name)
+		}
+		names[name] = true
+
+		backend := MLBackendConfig{Name: name, URL: fields[0], Class: fields[1], Model: fields[2], Weight: 1}
+		if backend.Class != "" && backend.Class != ModelFast && backend.Class != ModelAccurate {
+			return nil, fmt.Errorf("invalid ML backend %q: class must be fast, accurate or empty", entry)
+		}
+		if len(fields) == 4 {
+			weight, err := strconv.Atoi(fields[3])
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid ML backend %q: weight must be a positive integer", entry)
+			}
+			backend.Weight = weight
+		}
+		backends = append(backends, backend)
+	}
+
+	return backends, nil
+}
+
+// MLBackend is one ML service of a pool
+type MLBackend struct {
+	MLBackendConfig
+	Client *MLClient
+
+	healthy atomic.Bool
+}
+
+// Healthy reports whether the backend answered its last call or health check
+func (b *MLBackend) Healthy() bool {
+	return b.healthy.Load()
+}
+
+// MLPool spreads ML calls over several backends. Each call goes to a
+// healthy backend of the class asked for, picked at random by weight, and
+// moves on to the next of the class when a backend can't be reached or
+// fails. Backends are marked down when they fail and up again when they
+// next answer.
+type MLPool struct {
+	backends []*MLBackend
+}
+
+// NewMLPool creates a pool of the given backends, all assumed healthy
+// until they fail
+func NewMLPool(configs []MLBackendConfig) *MLPool {
+	pool := &MLPool{}
+	for _, cfg := range configs {
+		backend := &MLBackend{MLBackendConfig: cfg, Client: NewMLClient(cfg.Name, cfg.URL)}
+		backend.healthy.Store(true)
+		pool.backends = append(pool.backends, backend)
+	}
+	return pool
+}
+
+// Backends returns the pool's backends in configuration order
+func (p *MLPool) Backends() []*MLBackend {
+	return p.backends
+}
+
+// Route returns the order in which to try backends for a request of class,
+// or of any class when it is empty. Only backends of the class, or of no
+// class, are used: scores of different models can't be compared, so a
+// request is never quietly served by the other class. Healthy backends come
+// first, then those that are down, in case they have recovered. Within
+// each group the order is random, weighted by each backend's weight.
+func (p *MLPool) Route(class string) ([]*MLBackend, error) {
+	if class != "" && class != ModelFast && class != ModelAccurate {
+		return nil, ErrInvalidModel
+	}
+
+	var healthy, down []*MLBackend
+	for _, backend := range p.backends {
+		if class != "" && backend.Class != "" && backend.Class != class {
+			continue
+		}
+		if backend.Healthy() {
+			healthy = append(healthy, backend)
+		} else {
+			down = append(down, backend)
+		}
+	}
+	if len(healthy)+len(down) == 0 {
+		if class == "" {
+			return nil, fmt.Errorf("%w: none is configured", ErrMLUnavailable)
+		}
+		return nil, fmt.Errorf("%w: none serves the %s class", ErrMLUnavailable, class)
+	}
+
+	return append(weightedOrder(healthy), weightedOrder(down)...), nil
+}
+
+// Models returns the distinct models of backends, in order
+func Models(backends []*MLBackend) []string {
+	var models []string
+	seen := map[string]bool{}
+	for _, backend := range backends {
+		if !seen[backend.Model] {
+			seen[backend.Model] = true
+			models = append(models, backend.Model)
+		}
+	}
+	return models
+}
+
+// weightedOrder shuffles backends so that each comes first with a chance
+// proportional to its weight
+func weightedOrder(backends []*MLBackend) []*MLBackend {
+	remaining := append([]*MLBackend(nil), backends...)
+	order := make([]*MLBackend, 0, len(backends))
+
+	for len(remaining) > 0 {
+		total := 0
+		for _, backend := range remaining {
+			total += backend.Weight
+		}
+		pick := rand.Intn(total)
+		for i, backend := range remaining {
+			if pick < backend.Weight {
+				order = append(order, backend)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			pick -= backend.Weight
+		}
+	}
+	return order
+}
+
+// Analyze runs req on the first of backends that succeeds and returns the
+// backend with its response
+func (p *MLPool) Analyze(ctx context.Context, backends []*MLBackend, req AnalysisRequest) (*AnalysisResponse, *MLBackend, error) {
+	var resp *AnalysisResponse
+	backend, err := p.call(ctx, backends, "analyze", func(b *MLBackend) (err error) {
+		resp, err = b.Client.AnalyzePronunciation(ctx, req)
+		return err
+	})
+	return resp, backend, err
+}
+
+// Transcribe transcribes audio on the fast backends, or on any when there
+// are none. Transcriptions aren't scored, so any model will do.
+func (p *MLPool) Transcribe(ctx context.Context, audioData []byte, filename string) (*TranscriptionResponse, error) {
+	backends, err := p.Route(ModelFast)
+	if errors.Is(err, ErrMLUnavailable) {
+		backends, err = p.Route("")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *TranscriptionResponse
+	_, err = p.call(ctx, backends, "transcribe", func(b *MLBackend) (err error) {
+		resp, err = b.Client.Transcribe(ctx, audioData, filename)
+		return err
+	})
+	return resp, err
+}
+
+// call tries fn on each backend in turn until one succeeds. A backend that
+// rejects the request itself, with a 4xx, would be no different from the
+// next, so its error is returned straight away. When every backend fails
+// the error wraps ErrMLUnavailable.
+func (p *MLPool) call(ctx context.Context, backends []*MLBackend, endpoint string, fn func(b *MLBackend) error) (*MLBackend, error) {
+	var err error
+	for _, backend := range backends {
+		err = fn(backend)
+		if err == nil {
+			backend.healthy.Store(true)
+			return backend, nil
+		}
+
+		var statusErr *MLStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode < http.StatusInternalServerError {
+			backend.healthy.Store(true)
+			return backend, err
+		}
+		if ctx.Err() != nil {
+			return backend, err
+		}
+
+		backend.healthy.Store(false)
+		slog.WarnContext(ctx, "ML backend failed, trying the next", "backend", backend.Name, "endpoint", endpoint, "error", err)
+	}
+	return nil, fmt.Errorf("%w: %w", ErrMLUnavailable, err)
+}
+
+// Ping checks every backend's health concurrently, each bounded by
+// timeout, and marks it up or down. It fails only when no backend is up.
+func (p *MLPool) Ping(ctx context.Context, timeout time.Duration) error {
+	errs := make([]error, len(p.backends))
+	var wg sync.WaitGroup
+	for i, backend := range p.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pingCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			_, err := backend.Client.Health(pingCtx)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", backend.Name, err)
+			}
+			backend.healthy.Store(err == nil)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunHealthChecks pings every backend each interval until ctx is done, so
+// a backend that went down is used again once it recovers
+func (p *MLPool) RunHealthChecks(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Ping(ctx, timeout); err != nil && ctx.Err() == nil {
+				slog.Warn("no ML backend is healthy", "error", err)
+			}
+		}
+	}
+}
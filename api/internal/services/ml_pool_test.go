@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseMLBackends(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []MLBackendConfig
+		wantErr bool
+	}{
+		{
+			name: "two backends",
+			spec: "large=http://ml-large:8001|accurate|whisper-large-v3, small=http://ml-small:8001|fast|whisper-base|3",
+			want: []MLBackendConfig{
+				{Name: "large", URL: "http://ml-large:8001", Class: ModelAccurate, Model: "whisper-large-v3", Weight: 1},
+				{Name: "small", URL: "http://ml-small:8001", Class: ModelFast, Model: "whisper-base", Weight: 3},
+			},
+		},
+		{
+			name: "no class",
+			spec: "any=http://ml:8001||whisper-base",
+			want: []MLBackendConfig{{Name: "any", URL: "http://ml:8001", Model: "whisper-base", Weight: 1}},
+		},
+		{name: "no name", spec: "=http://ml:8001|fast|whisper-base", wantErr: true},
+		{name: "no url", spec: "ml=|fast|whisper-base", wantErr: true},
+		{name: "no model", spec: "ml=http://ml:8001|fast|", wantErr: true},
+		{name: "too few fields", spec: "ml=http://ml:8001|fast", wantErr: true},
+		{name: "too many fields", spec: "ml=http://ml:8001|fast|whisper-base|1|2", wantErr: true},
+		{name: "unknown class", spec: "ml=http://ml:8001|huge|whisper-base", wantErr: true},
+		{name: "zero weight", spec: "ml=http://ml:8001|fast|whisper-base|0", wantErr: true},
+		{name: "weight not a number", spec: "ml=http://ml:8001|fast|whisper-base|x", wantErr: true},
+		{name: "name twice", spec: "ml=http://a:8001|fast|whisper-base,ml=http://b:8001|fast|whisper-base", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMLBackends(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedOrder(t *testing.T) {
+	heavy := &MLBackend{MLBackendConfig: MLBackendConfig{Name: "heavy", Weight: 3}}
+	light := &MLBackend{MLBackendConfig: MLBackendConfig{Name: "light", Weight: 1}}
+
+	const rounds = 4000
+	first := map[string]int{}
+	for i := 0; i < rounds; i++ {
+		order := weightedOrder([]*MLBackend{heavy, light})
+		if len(order) != 2 || order[0] == order[1] {
+			t.Fatalf("order = %v, want each backend once", order)
+		}
+		first[order[0].Name]++
+	}
+
+	// heavy should come first about three times in four
+	if share := float64(first["heavy"]) / rounds; share < 0.7 || share > 0.8 {
+		t.Errorf("heavy came first in %.2f of rounds, want about 0.75", share)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	pool := NewMLPool([]MLBackendConfig{
+		{Name: "large", Class: ModelAccurate, Model: "whisper-large-v3", Weight: 1},
+		{Name: "small", Class: ModelFast, Model: "whisper-base", Weight: 1},
+		{Name: "spare", Class: ModelFast, Model: "whisper-base", Weight: 1},
+		{Name: "any", Model: "whisper-small", Weight: 1},
+	})
+	for _, backend := range pool.Backends() {
+		if backend.Name == "small" {
+			backend.healthy.Store(false)
+		}
+	}
+
+	tests := []struct {
+		name string
+		pool *MLPool
+		// class is requested; healthy and down are the backends expected
+		// first and last, in any order within each
+		class   string
+		healthy []string
+		down    []string
+		wantErr error
+	}{
+		{name: "accurate", pool: pool, class: ModelAccurate, healthy: []string{"large", "any"}},
+		{name: "fast with one down", pool: pool, class: ModelFast, healthy: []string{"spare", "any"}, down: []string{"small"}},
+		{name: "any class", pool: pool, healthy: []string{"large", "spare", "any"}, down: []string{"small"}},
+		{name: "unknown class", pool: pool, class: "huge", wantErr: ErrInvalidModel},
+		{
+			name:    "class without backends",
+			pool:    NewMLPool([]MLBackendConfig{{Name: "small", Class: ModelFast, Model: "whisper-base", Weight: 1}}),
+			class:   ModelAccurate,
+			wantErr: ErrMLUnavailable,
+		},
+		{name: "no backends", pool: NewMLPool(nil), wantErr: ErrMLUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := tt.pool.Route(tt.class)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, backend := range order {
+				names = append(names, backend.Name)
+			}
+			if len(names) != len(tt.healthy)+len(tt.down) {
+				t.Fatalf("order = %v, want %v then %v", names, tt.healthy, tt.down)
+			}
+			if !sameNames(names[:len(tt.healthy)], tt.healthy) || !sameNames(names[len(tt.healthy):], tt.down) {
+				t.Errorf("order = %v, want %v then %v", names, tt.healthy, tt.down)
+			}
+		})
+	}
+}
+
+func sameNames(got, want []string) bool {
+	seen := map[string]int{}
+	for _, name := range got {
+		seen[name]++
+	}
+	for _, name := range want {
+		seen[name]--
+	}
+	for _, n := range seen {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAnalyzeFailsOver(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"transcription": "hello", "score": 90}`))
+	}))
+	defer ok.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	tests := []struct {
+		name        string
+		urls        []string
+		wantBackend string
+		wantErr     error
+		// wantDown are the backends marked down afterwards
+		wantDown []string
+	}{
+		{name: "first answers", urls: []string{ok.URL, broken.URL}, wantBackend: "0"},
+		{name: "moves past a failing backend", urls: []string{broken.URL, ok.URL}, wantBackend: "1", wantDown: []string{"0"}},
+		{name: "all fail", urls: []string{broken.URL, broken.URL}, wantErr: ErrMLUnavailable, wantDown: []string{"0", "1"}},
+		{name: "stops at a rejected request", urls: []string{rejecting.URL, ok.URL}, wantBackend: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var configs []MLBackendConfig
+			for i, url := range tt.urls {
+				configs = append(configs, MLBackendConfig{Name: string(rune('0' + i)), URL: url, Model: "whisper-base", Weight: 1})
+			}
+			pool := NewMLPool(configs)
+
+			_, backend, err := pool.Analyze(context.Background(), pool.Backends(), AnalysisRequest{ExpectedText: "hello", AudioData: []byte("audio")})
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantBackend != "" && (backend == nil || backend.Name != tt.wantBackend) {
+				t.Fatalf("backend = %v, err = %v, want %s", backend, err, tt.wantBackend)
+			}
+
+			var down []string
+			for _, backend := range pool.Backends() {
+				if !backend.Healthy() {
+					down = append(down, backend.Name)
+				}
+			}
+			if !sameNames(down, tt.wantDown) {
+				t.Errorf("down = %v, want %v", down, tt.wantDown)
+			}
+		})
+	}
+}
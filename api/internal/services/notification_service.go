@@ -0,0 +1,64 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+)
+
+type NotificationService struct {
+	db *gorm.DB
+}
+
+func NewNotificationService(db *gorm.DB) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+func (s *NotificationService) ListNotifications(userID string, unreadOnly bool) ([]models.Notification, error) {
+	var notifications []models.Notification
+	query := s.db.Where("user_id = ?", userID).Order("created_at DESC")
+
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	if err := query.Find(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (s *NotificationService) MarkRead(id, userID string) error {
+	result := s.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if result.Error != nil {
+		return fmt.Errorf("failed to update notification: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// notify records a notification using tx so it commits together with the
+// change that caused it
+func notify(tx *gorm.DB, userID, notificationType string, sessionID *string, message string) error {
+	notification := &models.Notification{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Type:      notificationType,
+		SessionID: sessionID,
+		Message:   message,
+	}
+
+	if err := tx.Create(notification).Error; err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	return nil
+}
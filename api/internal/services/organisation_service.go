@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+)
+
+var (
+	ErrOrganisationNotFound = kindError(ErrNotFound, "organisation not found")
+	ErrInvalidOrganisation  = kindError(ErrInvalidInput, "invalid organisation")
+)
+
+type OrganisationService struct {
+	db *gorm.DB
+}
+
+func NewOrganisationService(db *gorm.DB) *OrganisationService {
+	return &OrganisationService{db: db}
+}
+
+// CreateOrganisation stores a new organisation. Nil settings get the
+// defaults.
+func (s *OrganisationService) CreateOrganisation(name string, settings *models.OrganisationSettings) (*models.Organisation, error) {
+	org := &models.Organisation{
+		ID:       uuid.New().String(),
+		Name:     strings.TrimSpace(name),
+		Settings: models.DefaultOrganisationSettings,
+	}
+	if org.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidOrganisation)
+	}
+	if settings != nil {
+		if err := validateSettings(*settings); err != nil {
+			return nil, err
+		}
+		org.Settings = *settings
+	}
+
+	if err := s.db.Create(org).Error; err != nil {
+		return nil, fmt.Errorf("failed to create organisation: %w", err)
+	}
+
+	return org, nil
+}
+
+func (s *OrganisationService) GetOrganisationByID(id string) (*models.Organisation, error) {
+	var org models.Organisation
+	if err := s.db.First(&org, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch organisation: %w", err)
+	}
+	return &org, nil
+}
+
+// ListOrganisations returns every organisation for the zero Tenant and
+// only the tenant's own otherwise
+func (s *OrganisationService) ListOrganisations(tenant Tenant) ([]models.Organisation, error) {
+	query := s.db.Order("name")
+	if tenant.OrganisationID != "" {
+		query = query.Where("id = ?", tenant.OrganisationID)
+	}
+
+	var orgs []models.Organisation
+	if err := query.Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch organisations: %w", err)
+	}
+	return orgs, nil
+}
+
+// UpdateOrganisationRequest holds the editable fields of an organisation.
+// Nil fields are left unchanged.
+type UpdateOrganisationRequest struct {
+	Name     *string
+	Settings *models.OrganisationSettings
+}
+
+// UpdateOrganisation changes an organisation. Tenants inside an
+// organisation can only change their own.
+func (s *OrganisationService) UpdateOrganisation(tenant Tenant, id string, req UpdateOrganisationRequest) (*models.Organisation, error) {
+	if tenant.OrganisationID != "" && tenant.OrganisationID != id {
+		return nil, ErrOrganisationNotFound
+	}
+
+	org, err := s.GetOrganisationByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrOrganisationNotFound
+	}
+
+	if req.Name != nil {
+		if org.Name = strings.TrimSpace(*req.Name); org.Name == "" {
+			return nil, fmt.Errorf("%w: name is required", ErrInvalidOrganisation)
+		}
+	}
+	if req.Settings != nil {
+		if err := validateSettings(*req.Settings); err != nil {
+			return nil, err
+		}
+		org.Settings = *req.Settings
+	}
+
+	if err := s.db.Save(org).Error; err != nil {
+		return nil, fmt.Errorf("failed to update organisation: %w", err)
+	}
+
+	return org, nil
+}
+
+// AssignUser moves a user into an organisation. Their existing sessions
+// move with them so their history stays visible to them.
+func (s *OrganisationService) AssignUser(orgID, userID string) (*models.User, error) {
+	org, err := s.GetOrganisationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrOrganisationNotFound
+	}
+
+	var user models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to fetch user: %w", err)
+		}
+
+		user.OrganisationID = &org.ID
+		if err := tx.Model(&user).Update("organisation_id", org.ID).Error; err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		if err := tx.Model(&models.Session{}).Where("user_id = ?", user.ID).Update("organisation_id", org.ID).Error; err != nil {
+			return fmt.Errorf("failed to move sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// TenantForUser returns the tenant a user acts for
+func (s *OrganisationService) TenantForUser(user *models.User) (Tenant, error) {
+	if user == nil || user.OrganisationID == nil {
+		return TenantOf(nil), nil
+	}
+	return s.TenantForOrganisation(*user.OrganisationID)
+}
+
+// TenantForOrganisation returns the tenant for an organisation ID
+func (s *OrganisationService) TenantForOrganisation(id string) (Tenant, error) {
+	org, err := s.GetOrganisationByID(id)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if org == nil {
+		return Tenant{}, ErrOrganisationNotFound
+	}
+	return TenantOf(org), nil
+}
+
+func validateSettings(settings models.OrganisationSettings) error {
+	if settings.FairScore < 0 || settings.FairScore > settings.GoodScore || settings.GoodScore > 100 {
+		return fmt.Errorf("%w: scores must satisfy 0 <= fair_score <= good_score <= 100", ErrInvalidOrganisation)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/storage"
+)
+
+// ErasedUserID replaces the author of feedback left on other learners'
+// sessions when that author erases their account
+const ErasedUserID = "erased-user"
+
+// PrivacyService implements data export and erasure requests
+type PrivacyService struct {
+	db          *gorm.DB
+	audioStore  storage.AudioStore
+	leaderboard *LeaderboardService
+	cache       *AnalysisCache
+}
+
+func NewPrivacyService(db *gorm.DB, audioStore storage.AudioStore, leaderboard *LeaderboardService, cache *AnalysisCache) *PrivacyService {
+	return &PrivacyService{
+		db:          db,
+		audioStore:  audioStore,
+		leaderboard: leaderboard,
+		cache:       cache,
+	}
+}
+
+// UserExport is everything held about a user, loaded before the archive is
+// written so database errors surface before any bytes are sent
+type UserExport struct {
+	User          *models.User
+	Sessions      []models.Session
+	Notifications []models.Notification
+	Comments      []models.FeedbackComment
+}
+
+func (s *PrivacyService) LoadUserExport(userID string) (*UserExport, error) {
+	export := &UserExport{}
+
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err == nil {
+		export.User = &user
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	err := s.db.Preload("Prompt").Preload("Feedback.Comments").
+		Where("user_id = ?", userID).Order("created_at ASC").
+		Find(&export.Sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sessions: %w", err)
+	}
+
+	if err := s.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&export.Notifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch notifications: %w", err)
+	}
+
+	if err := s.db.Where("author_id = ?", userID).Order("created_at ASC").Find(&export.Comments).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch comments: %w", err)
+	}
+
+	return export, nil
+}
+
+// WriteArchive writes the export as a ZIP of JSON documents plus a
+// recordings/ folder holding any stored audio
+func (s *PrivacyService) WriteArchive(export *UserExport, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	documents := []struct {
+		name string
+		data interface{}
+	}{
+		{"user.json", export.User},
+		{"sessions.json", export.Sessions},
+		{"notifications.json", export.Notifications},
+		{"comments.json", export.Comments},
+	}
+
+	for _, doc := range documents {
+		f, err := zw.Create(doc.name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to archive: %w", doc.name, err)
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc.data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", doc.name, err)
+		}
+	}
+
+	if s.audioStore != nil {
+		for _, session := range export.Sessions {
+			if session.AudioKey == nil {
+				continue
+			}
+			if err := s.addRecording(zw, *session.AudioKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PrivacyService) addRecording(zw *zip.Writer, key string) error {
+	src, err := s.audioStore.Open(key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := zw.Create("recordings/" + key)
+	if err != nil {
+		return fmt.Errorf("failed to add recording to archive: %w", err)
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	return nil
+}
+
+// EraseUser deletes the user's sessions (with their analysis data, feedback,
+// assignment completions and recordings), notifications, group memberships,
+// comments and finally the user row, then records an audit entry without
+// personal data.
+func (s *PrivacyService) EraseUser(userID string) (*models.ErasureAudit, error) {
+	audit := &models.ErasureAudit{ID: uuid.New().String()}
+	var audioKeys []string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND audio_key IS NOT NULL", userID).
+			Pluck("audio_key", &audioKeys).Error; err != nil {
+			return fmt.Errorf("failed to fetch recordings: %w", err)
+		}
+
+		result := tx.Delete(&models.Session{}, "user_id = ?", userID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete sessions: %w", result.Error)
+		}
+		audit.SessionsDeleted = result.RowsAffected
+
+		if err := tx.Delete(&models.AssignmentCompletion{}, "user_id = ?", userID).Error; err != nil {
+			return fmt.Errorf("failed to delete assignment completions: %w", err)
+		}
+
+		// Cached analyses and webhook payloads hold the sessions'
+		// transcriptions. Pending deliveries go too: the user's data
+		// shouldn't be sent on after they are erased.
+		if err := tx.Delete(&models.AnalysisCacheEntry{}, "user_id = ?", userID).Error; err != nil {
+			return fmt.Errorf("failed to delete cached analyses: %w", err)
+		}
+		if err := tx.Delete(&models.WebhookDelivery{}, "payload->'data'->>'user_id' = ?", userID).Error; err != nil {
+			return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+		}
+
+		// Stored Idempotency-Key responses would replay the sessions for a
+		// while yet: those of requests made as the user or with their API
+		// keys, and any others naming the user, e.g. a teacher's batch
+		ownKeys := tx.Model(&models.APIKey{}).Select("'key:' || id").Where("user_id = ?", userID)
+		if err := tx.Where("owner IN (?) OR owner = ? OR position(convert_to(?, 'UTF8') IN response_body) > 0", ownKeys, "user:"+userID, userID).
+			Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return fmt.Errorf("failed to delete idempotent responses: %w", err)
+		}
+
+		result = tx.Delete(&models.Notification{}, "user_id = ?", userID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete notifications: %w", result.Error)
+		}
+		audit.NotificationsDeleted = result.RowsAffected
+
+		result = tx.Delete(&models.FeedbackComment{}, "author_id = ?", userID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete comments: %w", result.Error)
+		}
+		audit.CommentsDeleted = result.RowsAffected
+
+		// Feedback a teacher left for others belongs to those learners too,
+		// so it is kept but no longer attributed
+		if err := tx.Model(&models.SessionFeedback{}).
+			Where("teacher_id = ?", userID).
+			Update("teacher_id", ErasedUserID).Error; err != nil {
+			return fmt.Errorf("failed to detach feedback: %w", err)
+		}
+
+		if err := tx.Delete(&models.GroupMember{}, "user_id = ?", userID).Error; err != nil {
+			return fmt.Errorf("failed to delete group memberships: %w", err)
+		}
+
+		result = tx.Delete(&models.User{}, "id = ?", userID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete user: %w", result.Error)
+		}
+		audit.UserRowDeleted = result.RowsAffected > 0
+
+		audit.RecordingsDeleted = int64(len(audioKeys))
+		if err := tx.Create(audit).Error; err != nil {
+			return fmt.Errorf("failed to record erasure: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.ForgetUser(userID)
+
+	if s.audioStore != nil {
+		for _, key := range audioKeys {
+			if err := s.audioStore.Delete(key); err != nil {
+				slog.Warn("failed to delete recording", "key", key, "error", err)
+			}
+		}
+	}
+
+	// Drop the user from the leaderboard aggregates now rather than on the
+	// next scheduled refresh
+	if err := s.leaderboard.Refresh(); err != nil {
+		slog.Warn("leaderboard refresh failed", "error", err)
+	}
+
+	return audit, nil
+}
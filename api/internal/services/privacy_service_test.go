@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"speaktrainer-api/internal/dbtest"
+)
+
+func TestEraseUserDeletesCopiesOfSessions(t *testing.T) {
+	db, mock := dbtest.NewMock(t)
+	cache := NewAnalysisCache(db, 10, time.Hour)
+	service := NewPrivacyService(db, nil, NewLeaderboardService(db), cache)
+
+	userID := "user-1"
+	mock.ExpectExec(`INSERT INTO "analysis_cache_entries"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	cache.Put(context.Background(), AnalysisSource{SessionID: "session-1", UserID: &userID}, "model", "backend", []byte("audio"), "text", &AnalysisResponse{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "audio_key" FROM "sessions"`).WillReturnRows(sqlmock.NewRows([]string{"audio_key"}))
+	mock.ExpectExec(`DELETE FROM "sessions" WHERE user_id = \$1`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "assignment_completions" WHERE user_id = \$1`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "analysis_cache_entries" WHERE user_id = \$1`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "webhook_deliveries" WHERE payload->'data'->>'user_id' = \$1`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM "idempotency_keys" WHERE owner IN \(SELECT 'key:' \|\| id FROM "api_keys" WHERE user_id = \$1\) OR owner = \$2 OR position\(convert_to\(\$3, 'UTF8'\) IN response_body\) > 0`).
+		WithArgs(userID, "user:"+userID, userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "notifications"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "feedback_comments"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "session_feedbacks"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "group_members"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "users"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "erasure_audits"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`REFRESH MATERIALIZED VIEW`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if _, err := service.EraseUser(userID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.getMemory(cache.key("model", []byte("audio"), "text")); ok {
+		t.Error("the erased user's analysis is still cached in memory")
+	}
+}
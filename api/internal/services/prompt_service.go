@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,17 +19,18 @@ func NewPromptService(db *gorm.DB) *PromptService {
 	return &PromptService{db: db}
 }
 
-func (s *PromptService) GetAllPrompts() ([]models.Prompt, error) {
+// GetAllPrompts returns the global library and the tenant's own prompts
+func (s *PromptService) GetAllPrompts(tenant Tenant) ([]models.Prompt, error) {
 	var prompts []models.Prompt
-	if err := s.db.Find(&prompts).Error; err != nil {
+	if err := s.db.Scopes(visiblePrompts(tenant)).Find(&prompts).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch prompts: %w", err)
 	}
 	return prompts, nil
 }
 
-func (s *PromptService) GetPromptByID(id string) (*models.Prompt, error) {
+func (s *PromptService) GetPromptByID(tenant Tenant, id string) (*models.Prompt, error) {
 	var prompt models.Prompt
-	if err := s.db.First(&prompt, "id = ?", id).Error; err != nil {
+	if err := s.db.Scopes(visiblePrompts(tenant)).First(&prompt, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -37,9 +39,9 @@ func (s *PromptService) GetPromptByID(id string) (*models.Prompt, error) {
 	return &prompt, nil
 }
 
-func (s *PromptService) GetRandomPrompt() (*models.Prompt, error) {
+func (s *PromptService) GetRandomPrompt(tenant Tenant) (*models.Prompt, error) {
 	var prompts []models.Prompt
-	if err := s.db.Find(&prompts).Error; err != nil {
+	if err := s.db.Scopes(visiblePrompts(tenant)).Find(&prompts).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch prompts: %w", err)
 	}
 
@@ -54,10 +56,13 @@ func (s *PromptService) GetRandomPrompt() (*models.Prompt, error) {
 	return &prompts[randomIndex], nil
 }
 
-func (s *PromptService) CreatePrompt(text string) (*models.Prompt, error) {
+// CreatePrompt adds a prompt private to the tenant's organisation, or to
+// the global library for the zero Tenant
+func (s *PromptService) CreatePrompt(tenant Tenant, text string) (*models.Prompt, error) {
 	prompt := &models.Prompt{
-		ID:   uuid.New().String(),
-		Text: text,
+		ID:             uuid.New().String(),
+		Text:           text,
+		OrganisationID: tenant.organisationID(),
 	}
 
 	if err := s.db.Create(prompt).Error; err != nil {
@@ -67,9 +72,12 @@ func (s *PromptService) CreatePrompt(text string) (*models.Prompt, error) {
 	return prompt, nil
 }
 
-func (s *PromptService) UpdatePrompt(id, text string) (*models.Prompt, error) {
+// UpdatePrompt changes one of the tenant's own prompts. Organisations can
+// use the global library but not edit it, so one school's changes never
+// reach another.
+func (s *PromptService) UpdatePrompt(tenant Tenant, id, text string) (*models.Prompt, error) {
 	var prompt models.Prompt
-	if err := s.db.First(&prompt, "id = ?", id).Error; err != nil {
+	if err := s.db.Scopes(ownedBy(tenant, "prompts")).First(&prompt, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -84,48 +92,81 @@ func (s *PromptService) UpdatePrompt(id, text string) (*models.Prompt, error) {
 	return &prompt, nil
 }
 
-func (s *PromptService) DeletePrompt(id string) error {
-	result := s.db.Delete(&models.Prompt{}, "id = ?", id)
+// DeletePrompt removes one of the tenant's own prompts
+func (s *PromptService) DeletePrompt(tenant Tenant, id string) error {
+	result := s.db.Scopes(ownedBy(tenant, "prompts")).Delete(&models.Prompt{}, "id = ?", id)
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete prompt: %w", result.Error)
 	}
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("prompt not found")
+		return ErrPromptNotFound
 	}
 	return nil
 }
 
+// ImportPrompts creates a prompt for each text the tenant can't see yet and
+// returns how many were created
+func (s *PromptService) ImportPrompts(tenant Tenant, texts []string) (int, error) {
+	var existing []string
+	if err := s.db.Model(&models.Prompt{}).Scopes(visiblePrompts(tenant)).Pluck("text", &existing).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch prompts: %w", err)
+	}
+
+	seen := make(map[string]bool, len(existing))
+	for _, text := range existing {
+		seen[text] = true
+	}
+
+	created := 0
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" || seen[text] {
+			continue
+		}
+		seen[text] = true
+
+		if _, err := s.CreatePrompt(tenant, text); err != nil {
+			return created, err
+		}
+		created++
+	}
+
+	return created, nil
+}
+
+// DefaultPrompts are seeded into an empty database
+var DefaultPrompts = []string{
+	"Hello world",
+	"How are you today?",
+	"The quick brown fox jumps over the lazy dog",
+	"She sells seashells by the seashore",
+	"Peter Piper picked a peck of pickled peppers",
+	"Red leather, yellow leather",
+	"I scream, you scream, we all scream for ice cream",
+	"How much wood would a woodchuck chuck",
+	"Sally sells seashells by the seashore",
+	"Round the rough and rugged rock the ragged rascal rudely ran",
+	"Where is the nearest grocery store?",
+}
+
 // Seed database with initial prompts
 func (s *PromptService) SeedPrompts() error {
+	_, err := s.SeedPromptsFrom(DefaultPrompts)
+	return err
+}
+
+// SeedPromptsFrom fills an empty global library with texts. It does
+// nothing if global prompts already exist and returns how many were created.
+func (s *PromptService) SeedPromptsFrom(texts []string) (int, error) {
 	// Check if prompts already exist
 	var count int64
-	if err := s.db.Model(&models.Prompt{}).Count(&count).Error; err != nil {
-		return fmt.Errorf("failed to count prompts: %w", err)
+	if err := s.db.Model(&models.Prompt{}).Scopes(visiblePrompts(Tenant{})).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count prompts: %w", err)
 	}
 
 	if count > 0 {
-		return nil // Already seeded
-	}
-
-	prompts := []models.Prompt{
-		{ID: uuid.New().String(), Text: "Hello world"},
-		{ID: uuid.New().String(), Text: "How are you today?"},
-		{ID: uuid.New().String(), Text: "The quick brown fox jumps over the lazy dog"},
-		{ID: uuid.New().String(), Text: "She sells seashells by the seashore"},
-		{ID: uuid.New().String(), Text: "Peter Piper picked a peck of pickled peppers"},
-		{ID: uuid.New().String(), Text: "Red leather, yellow leather"},
-		{ID: uuid.New().String(), Text: "I scream, you scream, we all scream for ice cream"},
-		{ID: uuid.New().String(), Text: "How much wood would a woodchuck chuck"},
-		{ID: uuid.New().String(), Text: "Sally sells seashells by the seashore"},
-		{ID: uuid.New().String(), Text: "Round the rough and rugged rock the ragged rascal rudely ran"},
-		{ID: uuid.New().String(), Text: "Where is the nearest grocery store?"},
+		return 0, nil // Already seeded
 	}
 
-	for _, prompt := range prompts {
-		if err := s.db.Create(&prompt).Error; err != nil {
-			return fmt.Errorf("failed to create prompt '%s': %w", prompt.Text, err)
-		}
-	}
-
-	return nil
-}
\ No newline at end of file
+	return s.ImportPrompts(Tenant{}, texts)
+}
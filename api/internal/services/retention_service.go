@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/storage"
+)
+
+// RetentionPolicy says how long session data is kept. A zero duration keeps
+// that kind of data forever. Scores, expected text and prompt links are
+// never purged, they remain as anonymised aggregates.
+type RetentionPolicy struct {
+	AudioRetention    time.Duration
+	AnalysisRetention time.Duration
+	BatchSize         int
+}
+
+// RetentionReport describes what a sweep removed, or in a dry run what it
+// would remove
+type RetentionReport struct {
+	DryRun             bool       `json:"dry_run"`
+	AudioCutoff        *time.Time `json:"audio_cutoff,omitempty"`
+	AnalysisCutoff     *time.Time `json:"analysis_cutoff,omitempty"`
+	RecordingsPurged   int64      `json:"recordings_purged"`
+	SessionsAnonymised int64      `json:"sessions_anonymised"`
+	GeneratedAt        time.Time  `json:"generated_at"`
+}
+
+type RetentionService struct {
+	db         *gorm.DB
+	audioStore storage.AudioStore
+	cache      *AnalysisCache
+	policy     RetentionPolicy
+}
+
+func NewRetentionService(db *gorm.DB, audioStore storage.AudioStore, cache *AnalysisCache, policy RetentionPolicy) *RetentionService {
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = 500
+	}
+	return &RetentionService{
+		db:         db,
+		audioStore: audioStore,
+		cache:      cache,
+		policy:     policy,
+	}
+}
+
+func (s *RetentionService) newReport(dryRun bool) *RetentionReport {
+	now := time.Now()
+	report := &RetentionReport{DryRun: dryRun, GeneratedAt: now}
+	if s.policy.AudioRetention > 0 {
+		cutoff := now.Add(-s.policy.AudioRetention)
+		report.AudioCutoff = &cutoff
+	}
+	if s.policy.AnalysisRetention > 0 {
+		cutoff := now.Add(-s.policy.AnalysisRetention)
+		report.AnalysisCutoff = &cutoff
+	}
+	return report
+}
+
+func (s *RetentionService) expiredAudio(cutoff time.Time) *gorm.DB {
+	return s.db.Model(&models.Session{}).Where("audio_key IS NOT NULL AND created_at < ?", cutoff)
+}
+
+func (s *RetentionService) expiredAnalyses(cutoff time.Time) *gorm.DB {
+	return s.db.Model(&models.Session{}).Where("anonymised_at IS NULL AND created_at < ?", cutoff)
+}
+
+// Report counts what a sweep would do right now without changing anything
+func (s *RetentionService) Report() (*RetentionReport, error) {
+	report := s.newReport(true)
+
+	if report.AudioCutoff != nil {
+		if err := s.expiredAudio(*report.AudioCutoff).Count(&report.RecordingsPurged).Error; err != nil {
+			return nil, fmt.Errorf("failed to count expired recordings: %w", err)
+		}
+	}
+
+	if report.AnalysisCutoff != nil {
+		if err := s.expiredAnalyses(*report.AnalysisCutoff).Count(&report.SessionsAnonymised).Error; err != nil {
+			return nil, fmt.Errorf("failed to count expired analyses: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// Sweep purges expired recordings and anonymises expired analyses in
+// batches, stopping early if ctx is cancelled
+func (s *RetentionService) Sweep(ctx context.Context) (*RetentionReport, error) {
+	report := s.newReport(false)
+
+	if report.AudioCutoff != nil {
+		// Recordings that fail to delete are left for the next sweep, and
+		// skipped for the rest of this one so the ones after them are reached
+		var failed []string
+		for ctx.Err() == nil {
+			n, fetched, err := s.purgeAudioBatch(*report.AudioCutoff, &failed)
+			if err != nil {
+				return report, err
+			}
+			report.RecordingsPurged += n
+			if fetched < s.policy.BatchSize {
+				break
+			}
+		}
+	}
+
+	if report.AnalysisCutoff != nil {
+		for ctx.Err() == nil {
+			n, err := s.anonymiseBatch(*report.AnalysisCutoff)
+			if err != nil {
+				return report, err
+			}
+			report.SessionsAnonymised += n
+			if n < int64(s.policy.BatchSize) {
+				break
+			}
+		}
+	}
+
+	return report, ctx.Err()
+}
+
+// purgeAudioBatch deletes up to one batch of expired recordings, other
+// than those of sessions in failed, and clears their keys. It returns how
+// many were purged and how many were fetched. Recordings that fail to
+// delete keep their key and are added to failed.
+func (s *RetentionService) purgeAudioBatch(cutoff time.Time, failed *[]string) (int64, int, error) {
+	query := s.expiredAudio(cutoff)
+	if len(*failed) > 0 {
+		query = query.Where("id NOT IN ?", *failed)
+	}
+
+	var sessions []models.Session
+	if err := query.Select("id", "audio_key").Limit(s.policy.BatchSize).Find(&sessions).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch expired recordings: %w", err)
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		if s.audioStore != nil {
+			if err := s.audioStore.Delete(*session.AudioKey); err != nil {
+				slog.Warn("failed to delete recording", "key", *session.AudioKey, "error", err)
+				*failed = append(*failed, session.ID)
+				continue
+			}
+		}
+		ids = append(ids, session.ID)
+	}
+
+	if len(ids) == 0 {
+		return 0, len(sessions), nil
+	}
+
+	if err := s.db.Model(&models.Session{}).Where("id IN ?", ids).Update("audio_key", nil).Error; err != nil {
+		return 0, len(sessions), fmt.Errorf("failed to clear recording keys: %w", err)
+	}
+
+	return int64(len(ids)), len(sessions), nil
+}
+
+// anonymiseBatch strips personal data from up to one batch of expired
+// sessions: the owner, transcription, phoneme analysis, notes, feedback,
+// notifications, cached analysis, webhook deliveries and any recording.
+// The score and expected text stay.
+func (s *RetentionService) anonymiseBatch(cutoff time.Time) (int64, error) {
+	var sessions []models.Session
+	if err := s.expiredAnalyses(cutoff).Select("id", "audio_key").Limit(s.policy.BatchSize).Find(&sessions).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch expired analyses: %w", err)
+	}
+
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.SessionFeedback{}, "session_id IN ?", ids).Error; err != nil {
+			return fmt.Errorf("failed to delete feedback: %w", err)
+		}
+		if err := tx.Delete(&models.Notification{}, "session_id IN ?", ids).Error; err != nil {
+			return fmt.Errorf("failed to delete notifications: %w", err)
+		}
+		if err := tx.Delete(&models.AnalysisCacheEntry{}, "session_id IN ?", ids).Error; err != nil {
+			return fmt.Errorf("failed to delete cached analyses: %w", err)
+		}
+		if err := tx.Delete(&models.AssignmentCompletion{}, "session_id IN ?", ids).Error; err != nil {
+			return fmt.Errorf("failed to delete assignment completions: %w", err)
+		}
+		if err := tx.Delete(&models.WebhookDelivery{}, "payload->'data'->>'session_id' IN ?", ids).Error; err != nil {
+			return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+		}
+		return tx.Model(&models.Session{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"user_id":       nil,
+			"transcription": "",
+			"analysis_data": nil,
+			"notes":         "",
+			"favourite":     false,
+			"audio_key":     nil,
+			"anonymised_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to anonymise sessions: %w", err)
+	}
+	s.cache.ForgetSessions(ids)
+
+	// Rows no longer point at the files, so failures only leave orphans
+	if s.audioStore != nil {
+		for _, session := range sessions {
+			if session.AudioKey == nil {
+				continue
+			}
+			if err := s.audioStore.Delete(*session.AudioKey); err != nil {
+				slog.Warn("failed to delete recording", "key", *session.AudioKey, "error", err)
+			}
+		}
+	}
+
+	return int64(len(ids)), nil
+}
+
+// RunSweeper sweeps every interval until ctx is done, logging each run
+func (s *RetentionService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.Sweep(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("retention sweep failed", "error", err)
+			}
+			if report != nil && (report.RecordingsPurged > 0 || report.SessionsAnonymised > 0) {
+				slog.Info("retention sweep finished",
+					"recordings_purged", report.RecordingsPurged, "sessions_anonymised", report.SessionsAnonymised)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"speaktrainer-api/internal/dbtest"
+)
+
+// memoryAudioStore is an AudioStore whose deletes fail for keys in broken
+type memoryAudioStore struct {
+	broken  map[string]bool
+	deleted []string
+}
+
+func (m *memoryAudioStore) Save(key string, data []byte) error { return nil }
+func (m *memoryAudioStore) Open(key string) (io.ReadCloser, error) {
+	return nil, errors.New("not stored")
+}
+
+func (m *memoryAudioStore) Delete(key string) error {
+	if m.broken[key] {
+		return errors.New("permission denied")
+	}
+	m.deleted = append(m.deleted, key)
+	return nil
+}
+
+func TestSweepSkipsRecordingsThatFailToDelete(t *testing.T) {
+	db, mock := dbtest.NewMock(t)
+	store := &memoryAudioStore{broken: map[string]bool{"bad.webm": true}}
+	service := NewRetentionService(db, store, nil, RetentionPolicy{AudioRetention: 24 * time.Hour, BatchSize: 2})
+
+	recordings := func(ids ...string) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "audio_key"})
+		for _, id := range ids {
+			rows.AddRow(id, id+".webm")
+		}
+		return rows
+	}
+
+	// The broken recording heads the first batch; the second batch must
+	// move past it rather than fetch it again
+	mock.ExpectQuery(`SELECT "id","audio_key" FROM "sessions" WHERE audio_key IS NOT NULL AND created_at < \$1 LIMIT \$2`).
+		WillReturnRows(recordings("bad", "a"))
+	mock.ExpectExec(`UPDATE "sessions" SET "audio_key"=\$1`).WithArgs(nil, sqlmock.AnyArg(), "a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`AND id NOT IN \(\$2\) LIMIT \$3`).WithArgs(sqlmock.AnyArg(), "bad", 2).
+		WillReturnRows(recordings("b"))
+	mock.ExpectExec(`UPDATE "sessions" SET "audio_key"=\$1`).WithArgs(nil, sqlmock.AnyArg(), "b").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	report, err := service.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.RecordingsPurged != 2 {
+		t.Errorf("RecordingsPurged = %d, want 2", report.RecordingsPurged)
+	}
+	if len(store.deleted) != 2 || store.deleted[0] != "a.webm" || store.deleted[1] != "b.webm" {
+		t.Errorf("deleted %v, want [a.webm b.webm]", store.deleted)
+	}
+}
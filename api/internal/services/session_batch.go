@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"speaktrainer-api/internal/models"
+)
+
+// BatchItem is one recording of a batch. Its audio is read when its turn
+// comes, so a batch never holds more recordings in memory than it analyses
+// at once.
+type BatchItem struct {
+	Request   CreateSessionRequest
+	ReadAudio func() ([]byte, error)
+}
+
+// BatchResult is the outcome of one item of a batch: Result when it was
+// analysed, Err when it wasn't
+type BatchResult struct {
+	Result *SessionAnalysisResult
+	Err    error
+}
+
+// AnalyzeBatch analyses every item, at most concurrency at a time, and
+// returns their outcomes in the same order. An item failing doesn't stop
+// the others. Users named by the items must belong to tenant, since
+// batches record sessions on behalf of others.
+func (s *SessionService) AnalyzeBatch(ctx context.Context, tenant Tenant, items []BatchItem, concurrency int) ([]BatchResult, error) {
+	known, err := s.tenantUserIDs(ctx, tenant, items)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(items))
+	slots := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		req := item.Request
+		req.Tenant = tenant
+		if req.UserID != nil && !known[*req.UserID] {
+			results[i].Err = ErrUserNotFound
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			var err error
+			if req.AudioData, err = item.ReadAudio(); err != nil {
+				results[i].Err = err
+				return
+			}
+			results[i].Result, results[i].Err = s.AnalyzePronunciation(ctx, req)
+		}()
+	}
+
+	wg.Wait()
+	return results, nil
+}
+
+// tenantUserIDs returns which of the users named by items belong to tenant
+func (s *SessionService) tenantUserIDs(ctx context.Context, tenant Tenant, items []BatchItem) (map[string]bool, error) {
+	var ids []string
+	for _, item := range items {
+		if item.Request.UserID != nil {
+			ids = append(ids, *item.Request.UserID)
+		}
+	}
+
+	known := map[string]bool{}
+	if len(ids) == 0 {
+		return known, nil
+	}
+
+	var found []string
+	err := s.db.WithContext(ctx).Model(&models.User{}).Scopes(ownedBy(tenant, "users")).
+		Where("id IN ?", ids).Pluck("id", &found).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	for _, id := range found {
+		known[id] = true
+	}
+	return known, nil
+}
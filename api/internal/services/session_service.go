@@ -1,30 +1,62 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"speaktrainer-api/internal/metrics"
 	"speaktrainer-api/internal/models"
+	"speaktrainer-api/internal/progress"
+	"speaktrainer-api/internal/storage"
+	"speaktrainer-api/internal/tracing"
 )
 
+// ErrInvalidSessionID rejects a session ID chosen by the client
+var ErrInvalidSessionID = kindError(ErrInvalidInput, "session_id must be an unused UUID")
+
 type SessionService struct {
-	db       *gorm.DB
-	mlClient *MLClient
+	db         *gorm.DB
+	ml         *MLPool
+	audioStore storage.AudioStore
+	cache      *AnalysisCache
+	progress   *progress.Bus
 }
 
-func NewSessionService(db *gorm.DB, mlClient *MLClient) *SessionService {
+// NewSessionService creates the session service. audioStore may be nil, in
+// which case recordings are analysed but not kept, cache may be nil to run
+// every analysis, and progressBus may be nil when nobody watches analyses
+// as they run.
+func NewSessionService(db *gorm.DB, ml *MLPool, audioStore storage.AudioStore, cache *AnalysisCache, progressBus *progress.Bus) *SessionService {
 	return &SessionService{
-		db:       db,
-		mlClient: mlClient,
+		db:         db,
+		ml:         ml,
+		audioStore: audioStore,
+		cache:      cache,
+		progress:   progressBus,
 	}
 }
 
 type CreateSessionRequest struct {
+	Tenant Tenant
+	// SessionID is optional; a client that chooses it can watch the
+	// analysis's progress before the response arrives
+	SessionID    string
 	ExpectedText string
 	UserID       *string
+	PromptID     *string
 	AudioData    []byte
 	Filename     string
+	// Model is the class of ML model to use, fast or accurate; empty lets
+	// the pool choose
+	Model string
 }
 
 type SessionAnalysisResult struct {
@@ -32,7 +64,97 @@ type SessionAnalysisResult struct {
 	AnalysisDetails *AnalysisResponse `json:"analysis_details"`
 }
 
-func (s *SessionService) AnalyzePronunciation(req CreateSessionRequest) (*SessionAnalysisResult, error) {
+// AnalyzePronunciation scores a recording and saves it as a new session,
+// publishing each stage it reaches to the progress bus
+func (s *SessionService) AnalyzePronunciation(ctx context.Context, req CreateSessionRequest) (*SessionAnalysisResult, error) {
+	if err := s.assignSessionID(ctx, &req); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, req, progress.Event{Stage: progress.StageReceived})
+
+	result, err := s.analyze(ctx, req)
+	if err != nil {
+		// Only the caller's mistakes are worth telling a watcher about
+		event := progress.Event{Stage: progress.StageFailed, Error: "analysis failed"}
+		if errors.Is(err, ErrInvalidInput) || errors.Is(err, ErrNotFound) {
+			event.Error = err.Error()
+		}
+		s.publish(ctx, req, event)
+		return nil, err
+	}
+
+	s.publish(ctx, req, progress.Event{
+		Stage:         progress.StageSaved,
+		Transcription: result.Session.Transcription,
+		Score:         &result.Session.Score,
+	})
+	return result, nil
+}
+
+// assignSessionID gives the request a new session ID, or checks the one the
+// client chose. Two concurrent requests with the same ID still can't both
+// save, since it is the primary key.
+func (s *SessionService) assignSessionID(ctx context.Context, req *CreateSessionRequest) error {
+	if req.SessionID == "" {
+		req.SessionID = uuid.New().String()
+		return nil
+	}
+	if _, err := uuid.Parse(req.SessionID); err != nil {
+		return ErrInvalidSessionID
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", req.SessionID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check session ID: %w", err)
+	}
+	if count > 0 {
+		return ErrInvalidSessionID
+	}
+	return nil
+}
+
+func (s *SessionService) publish(ctx context.Context, req CreateSessionRequest, event progress.Event) {
+	event.SessionID = req.SessionID
+	event.OrganisationID = req.Tenant.OrganisationID
+	if req.UserID != nil {
+		event.UserID = *req.UserID
+	}
+	s.progress.Publish(ctx, event)
+}
+
+func (s *SessionService) analyze(ctx context.Context, req CreateSessionRequest) (*SessionAnalysisResult, error) {
+	// Sessions may only be recorded for users of the tenant, whichever
+	// route the request came in by
+	if req.UserID != nil {
+		var count int64
+		err := s.db.WithContext(ctx).Model(&models.User{}).Scopes(ownedBy(req.Tenant, "users")).
+			Where("id = ?", *req.UserID).Count(&count).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch user: %w", err)
+		}
+		if count == 0 {
+			return nil, ErrUserNotFound
+		}
+	}
+
+	// Sessions may only link prompts their organisation can see
+	if req.PromptID != nil {
+		var count int64
+		err := s.db.WithContext(ctx).Model(&models.Prompt{}).Scopes(visiblePrompts(req.Tenant)).
+			Where("id = ?", *req.PromptID).Count(&count).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch prompt: %w", err)
+		}
+		if count == 0 {
+			return nil, ErrPromptNotFound
+		}
+	}
+	backends, err := s.ml.Route(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, req, progress.Event{Stage: progress.StageValidated})
+
 	// 1. Call ML service for analysis directly with expected text
 	analysisReq := AnalysisRequest{
 		ExpectedText: req.ExpectedText,
@@ -40,71 +162,442 @@ func (s *SessionService) AnalyzePronunciation(req CreateSessionRequest) (*Sessio
 		Filename:     req.Filename,
 	}
 
-	analysisResp, err := s.mlClient.AnalyzePronunciation(analysisReq)
-	if err != nil {
-		return nil, fmt.Errorf("ML analysis failed: %w", err)
+	// Resubmissions of the same recording reuse the earlier analysis, if
+	// it was made by a model of the class asked for
+	var (
+		analysisResp *AnalysisResponse
+		backendName  string
+		model        string
+		cached       bool
+	)
+	for _, model = range Models(backends) {
+		if analysisResp, backendName, cached = s.cache.Get(ctx, model, req.AudioData, req.ExpectedText); cached {
+			break
+		}
+	}
+	if !cached {
+		s.publish(ctx, req, progress.Event{Stage: progress.StageSentToML})
+		resp, backend, err := s.ml.Analyze(ctx, backends, analysisReq)
+		if err != nil {
+			s.announceFailure(ctx, req)
+			return nil, fmt.Errorf("ML analysis failed: %w", err)
+		}
+		analysisResp, backendName, model = resp, backend.Name, backend.Model
+		source := AnalysisSource{SessionID: req.SessionID, UserID: req.UserID}
+		s.cache.Put(ctx, source, model, backendName, req.AudioData, req.ExpectedText, analysisResp)
 	}
 
+	// The ML service transcribes and scores in one call, so these two
+	// stages are reached together
+	s.publish(ctx, req, progress.Event{Stage: progress.StageTranscribed, Transcription: analysisResp.Transcription})
+	s.publish(ctx, req, progress.Event{Stage: progress.StageScored, Score: &analysisResp.Score})
+
 	// 2. Create session record - just store the expected text directly
 	session := &models.Session{
-		ID:            uuid.New().String(),
-		ExpectedText:  req.ExpectedText, // Store text directly, no prompt reference
-		UserID:        req.UserID,
-		Transcription: analysisResp.Transcription,
-		Score:         analysisResp.Score,
-		AnalysisData: map[string]interface{}{
-			"expected_phonemes":  analysisResp.ExpectedPhonemes,
-			"actual_phonemes":    analysisResp.ActualPhonemes,
-			"diff":               analysisResp.Diff,
-			"phoneme_comparison": analysisResp.PhonemeComparison,
-		},
+		ID:             req.SessionID,
+		ExpectedText:   req.ExpectedText, // Store text directly, no prompt reference
+		UserID:         req.UserID,
+		OrganisationID: req.Tenant.organisationID(),
+		PromptID:       req.PromptID,
+		Transcription:  analysisResp.Transcription,
+		Score:          analysisResp.Score,
+		AnalysisData:   analysisData(analysisResp),
+		Cached:         cached,
+		MLBackend:      &backendName,
+		MLModel:        &model,
+	}
+
+	// 3. Keep the recording when audio storage is enabled
+	if s.audioStore != nil {
+		key := session.ID + strings.ToLower(filepath.Ext(req.Filename))
+		_, span := tracing.Tracer().Start(ctx, "store recording")
+		err := s.audioStore.Save(key, req.AudioData)
+		span.End()
+		if err != nil {
+			return nil, err
+		}
+		session.AudioKey = &key
 	}
 
-	if err := s.db.Create(session).Error; err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+	// The webhook outbox is written with the session, so subscribers hear
+	// about exactly the sessions that were saved, and the assignments they
+	// completed
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		if err := enqueueWebhooks(tx, session.OrganisationID, models.EventSessionAnalyzed,
+			newSessionEvent(session, req.Tenant.Settings)); err != nil {
+			return err
+		}
+		return completeAssignments(tx, session, req.Tenant.Settings)
+	})
+	if err != nil {
+		s.deleteRecording(session.AudioKey)
+		return nil, err
 	}
+	metrics.ObserveScore(session.Score)
 
-	// 3. Return complete result
+	// 4. Return complete result
 	return &SessionAnalysisResult{
 		Session:         session,
 		AnalysisDetails: analysisResp,
 	}, nil
 }
 
-func (s *SessionService) GetSessionByID(id string) (*models.Session, error) {
+// Transcribe transcribes audio without analysing or saving it, e.g. for
+// interim feedback while a learner is still speaking. Fast backends are
+// preferred, since speed matters more than accuracy here.
+func (s *SessionService) Transcribe(ctx context.Context, audioData []byte, filename string) (string, error) {
+	resp, err := s.ml.Transcribe(ctx, audioData, filename)
+	if err != nil {
+		return "", fmt.Errorf("ML transcription failed: %w", err)
+	}
+	return resp.Transcription, nil
+}
+
+// SessionEvent is the data of session.analyzed webhooks
+type SessionEvent struct {
+	SessionID     string    `json:"session_id"`
+	UserID        *string   `json:"user_id"`
+	PromptID      *string   `json:"prompt_id"`
+	ExpectedText  string    `json:"expected_text"`
+	Transcription string    `json:"transcription"`
+	Score         int       `json:"score"`
+	Rating        string    `json:"rating"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func newSessionEvent(session *models.Session, settings models.OrganisationSettings) SessionEvent {
+	return SessionEvent{
+		SessionID:     session.ID,
+		UserID:        session.UserID,
+		PromptID:      session.PromptID,
+		ExpectedText:  session.ExpectedText,
+		Transcription: session.Transcription,
+		Score:         session.Score,
+		Rating:        settings.Rating(session.Score),
+		CreatedAt:     session.CreatedAt,
+	}
+}
+
+// SessionFailedEvent is the data of session.failed webhooks, sent when an
+// attempt couldn't be analysed and so no session was saved
+type SessionFailedEvent struct {
+	UserID       *string `json:"user_id"`
+	PromptID     *string `json:"prompt_id"`
+	ExpectedText string  `json:"expected_text"`
+	Error        string  `json:"error"`
+}
+
+// announceFailure queues session.failed webhooks. It logs rather than
+// fails, since the caller already has an error to report.
+func (s *SessionService) announceFailure(ctx context.Context, req CreateSessionRequest) {
+	err := enqueueWebhooks(s.db.WithContext(ctx), req.Tenant.organisationID(), models.EventSessionFailed, SessionFailedEvent{
+		UserID:       req.UserID,
+		PromptID:     req.PromptID,
+		ExpectedText: req.ExpectedText,
+		Error:        "analysis failed",
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to queue session.failed webhooks", "error", err)
+	}
+}
+
+// analysisData is the part of an ML response persisted with the session
+func analysisData(resp *AnalysisResponse) map[string]interface{} {
+	return map[string]interface{}{
+		"expected_phonemes":  resp.ExpectedPhonemes,
+		"actual_phonemes":    resp.ActualPhonemes,
+		"diff":               resp.Diff,
+		"phoneme_comparison": resp.PhonemeComparison,
+	}
+}
+
+// RescoreSession runs the ML analysis again on a session's stored recording,
+// e.g. after a model upgrade, on a backend of the model class given (any
+// when empty). It returns nil when the session doesn't exist.
+func (s *SessionService) RescoreSession(ctx context.Context, id, model string) (*models.Session, error) {
+	backends, err := s.ml.Route(model)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx)
+
 	var session models.Session
-	if err := s.db.Preload("Prompt").First(&session, "id = ?", id).Error; err != nil {
+	if err := db.First(&session, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to fetch session: %w", err)
 	}
+
+	if s.audioStore == nil || session.AudioKey == nil {
+		return nil, fmt.Errorf("session %s has no stored recording", id)
+	}
+
+	recording, err := s.audioStore.Open(*session.AudioKey)
+	if err != nil {
+		return nil, err
+	}
+	defer recording.Close()
+
+	audioData, err := io.ReadAll(recording)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	analysisResp, backend, err := s.ml.Analyze(ctx, backends, AnalysisRequest{
+		ExpectedText: session.ExpectedText,
+		AudioData:    audioData,
+		Filename:     *session.AudioKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ML analysis failed: %w", err)
+	}
+
+	session.Transcription = analysisResp.Transcription
+	session.Score = analysisResp.Score
+	session.AnalysisData = analysisData(analysisResp)
+	session.MLBackend = &backend.Name
+	session.MLModel = &backend.Model
+
+	err = db.Model(&session).Updates(map[string]interface{}{
+		"transcription": session.Transcription,
+		"score":         session.Score,
+		"analysis_data": session.AnalysisData,
+		"ml_backend":    session.MLBackend,
+		"ml_model":      session.MLModel,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
 	return &session, nil
 }
 
-func (s *SessionService) GetSessionsByUser(userID string, limit, offset int) ([]models.Session, error) {
-	var sessions []models.Session
-	query := s.db.Preload("Prompt").Order("created_at DESC")
-	
-	if userID != "" {
-		query = query.Where("user_id = ?", userID)
-	}
-	
+// RecordedSessionIDs lists, oldest first, sessions matching filter that
+// still have a stored recording
+func (s *SessionService) RecordedSessionIDs(filter SessionFilter, limit int) ([]string, error) {
+	query := s.filterSessions(s.db.Model(&models.Session{}), filter).
+		Where("audio_key IS NOT NULL").
+		Order("created_at ASC")
+
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
-	
-	if offset > 0 {
-		query = query.Offset(offset)
+
+	var ids []string
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch sessions: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (s *SessionService) GetSessionByID(tenant Tenant, id string) (*models.Session, error) {
+	var session models.Session
+	query := s.db.Scopes(ownedBy(tenant, "sessions")).Preload("Prompt").
+		Preload("Feedback", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Feedback.Comments", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") })
+	if err := query.First(&session, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch session: %w", err)
+	}
+	return &session, nil
+}
+
+// UpdateSessionRequest holds the learner-editable fields of a session.
+// Nil fields are left unchanged.
+type UpdateSessionRequest struct {
+	Notes     *string
+	Favourite *bool
+}
+
+func (s *SessionService) UpdateSession(tenant Tenant, id string, req UpdateSessionRequest) (*models.Session, error) {
+	var session models.Session
+	if err := s.db.Scopes(ownedBy(tenant, "sessions")).First(&session, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch session: %w", err)
+	}
+
+	updates := map[string]interface{}{}
+	if req.Notes != nil {
+		updates["notes"] = *req.Notes
+	}
+	if req.Favourite != nil {
+		updates["favourite"] = *req.Favourite
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&session).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update session: %w", err)
+		}
+	}
+
+	return &session, nil
+}
+
+// DeleteSession removes a session together with everything stored for it
+func (s *SessionService) DeleteSession(tenant Tenant, id string) error {
+	var audioKey *string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.Scopes(ownedBy(tenant, "sessions")).Select("id", "audio_key").First(&session, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrSessionNotFound
+			}
+			return fmt.Errorf("failed to fetch session: %w", err)
+		}
+		audioKey = session.AudioKey
+
+		result := tx.Delete(&models.Session{}, "id = ?", id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete session: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrSessionNotFound
+		}
+		// Feedback and comments cascade; notifications only reference the session
+		if err := tx.Delete(&models.Notification{}, "session_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to delete session notifications: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The file is only removed once the row is gone for good
+	s.deleteRecording(audioKey)
+	return nil
+}
+
+// deleteRecording removes a stored recording, logging rather than failing
+// since the session row it belonged to is already gone
+func (s *SessionService) deleteRecording(key *string) {
+	if s.audioStore == nil || key == nil {
+		return
+	}
+	if err := s.audioStore.Delete(*key); err != nil {
+		slog.Warn("failed to delete recording", "key", *key, "error", err)
+	}
+}
+
+// MaxSessionPageSize caps how many sessions a single list call returns
+const MaxSessionPageSize = 100
+
+type SessionFilter struct {
+	UserID       string
+	PromptID     string
+	MinScore     *int
+	MaxScore     *int
+	From         *time.Time
+	To           *time.Time
+	TextContains string
+	Favourite    *bool
+}
+
+// SessionPage selects a page either by offset (legacy clients) or by a
+// keyset cursor on (created_at, id). Cursor takes precedence over Offset.
+type SessionPage struct {
+	Limit        int
+	Offset       int
+	Cursor       *SessionCursor
+	IncludeTotal bool
+}
+
+type SessionList struct {
+	Sessions   []models.Session
+	NextCursor string
+	Total      *int64
+}
+
+func (s *SessionService) ListSessions(tenant Tenant, filter SessionFilter, page SessionPage) (*SessionList, error) {
+	if page.Limit <= 0 || page.Limit > MaxSessionPageSize {
+		page.Limit = MaxSessionPageSize
+	}
+
+	base := s.filterSessions(s.db.Model(&models.Session{}).Scopes(ownedBy(tenant, "sessions")), filter)
+
+	list := &SessionList{}
+	if page.IncludeTotal {
+		var total int64
+		if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, fmt.Errorf("failed to count sessions: %w", err)
+		}
+		list.Total = &total
+	}
+
+	query := base.Session(&gorm.Session{}).Preload("Prompt").Order("created_at DESC, id DESC")
+	if page.Cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", page.Cursor.CreatedAt, page.Cursor.ID)
+	} else if page.Offset > 0 {
+		query = query.Offset(page.Offset)
 	}
 
-	if err := query.Find(&sessions).Error; err != nil {
+	// Fetch one extra row to know whether another page follows
+	var sessions []models.Session
+	if err := query.Limit(page.Limit + 1).Find(&sessions).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch sessions: %w", err)
 	}
 
-	return sessions, nil
+	if len(sessions) > page.Limit {
+		sessions = sessions[:page.Limit]
+		last := sessions[len(sessions)-1]
+		list.NextCursor = EncodeSessionCursor(SessionCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	list.Sessions = sessions
+
+	return list, nil
+}
+
+func (s *SessionService) filterSessions(query *gorm.DB, filter SessionFilter) *gorm.DB {
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.PromptID != "" {
+		query = query.Where("prompt_id = ?", filter.PromptID)
+	}
+	if filter.MinScore != nil {
+		query = query.Where("score >= ?", *filter.MinScore)
+	}
+	if filter.MaxScore != nil {
+		query = query.Where("score <= ?", *filter.MaxScore)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if filter.Favourite != nil {
+		query = query.Where("favourite = ?", *filter.Favourite)
+	}
+	if filter.TextContains != "" {
+		query = query.Where("expected_text ILIKE ?", "%"+escapeLike(filter.TextContains)+"%")
+	}
+	return query
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
-func (s *SessionService) GetAllSessions(limit, offset int) ([]models.Session, error) {
-	return s.GetSessionsByUser("", limit, offset)
-}
\ No newline at end of file
+func (s *SessionService) GetSessionsByUser(tenant Tenant, userID string, limit, offset int) ([]models.Session, error) {
+	list, err := s.ListSessions(tenant, SessionFilter{UserID: userID}, SessionPage{Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+	return list.Sessions, nil
+}
+
+func (s *SessionService) GetAllSessions(tenant Tenant, limit, offset int) ([]models.Session, error) {
+	return s.GetSessionsByUser(tenant, "", limit, offset)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"speaktrainer-api/internal/dbtest"
+)
+
+func TestAnalyzeChecksUserBelongsToTenant(t *testing.T) {
+	userID := "user-1"
+	tests := []struct {
+		name    string
+		tenant  Tenant
+		scope   string
+		args    []driver.Value
+		found   int
+		wantErr error
+	}{
+		{
+			name:    "user of another organisation",
+			tenant:  Tenant{OrganisationID: "org-1"},
+			scope:   `id = \$1 AND users\.organisation_id = \$2`,
+			args:    []driver.Value{userID, "org-1"},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:    "organisation user without a tenant",
+			scope:   `id = \$1 AND users\.organisation_id IS NULL`,
+			args:    []driver.Value{userID},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:   "user of the tenant",
+			tenant: Tenant{OrganisationID: "org-1"},
+			scope:  `id = \$1 AND users\.organisation_id = \$2`,
+			args:   []driver.Value{userID, "org-1"},
+			found:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := dbtest.NewMock(t)
+			mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE ` + tt.scope).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.found))
+
+			// No ML backend is configured, so a request that gets past the
+			// user check fails when it is routed
+			service := NewSessionService(db, NewMLPool(nil), nil, nil, nil)
+			_, err := service.AnalyzePronunciation(context.Background(), CreateSessionRequest{
+				Tenant:       tt.tenant,
+				ExpectedText: "hello",
+				UserID:       &userID,
+				AudioData:    []byte("audio"),
+			})
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (err == nil || errors.Is(err, ErrUserNotFound)) {
+				t.Fatalf("err = %v, want the request to reach routing", err)
+			}
+		})
+	}
+}
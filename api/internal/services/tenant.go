@@ -0,0 +1,55 @@
+package services
+
+import (
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+)
+
+// Tenant is the organisation a caller acts for. The zero Tenant is outside
+// any organisation: it sees the global prompt library and the users and
+// sessions that belong to no organisation, which is how a deployment
+// without organisations keeps working.
+type Tenant struct {
+	OrganisationID string
+	Settings       models.OrganisationSettings
+}
+
+// TenantOf returns the tenant for org, or the zero Tenant (with default
+// settings) when org is nil
+func TenantOf(org *models.Organisation) Tenant {
+	if org == nil {
+		return Tenant{Settings: models.DefaultOrganisationSettings}
+	}
+	return Tenant{OrganisationID: org.ID, Settings: org.Settings}
+}
+
+// organisationID is the value stored in organisation_id for rows the tenant
+// creates
+func (t Tenant) organisationID() *string {
+	if t.OrganisationID == "" {
+		return nil
+	}
+	id := t.OrganisationID
+	return &id
+}
+
+// ownedBy limits a query on table to rows belonging to the tenant
+func ownedBy(t Tenant, table string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if t.OrganisationID == "" {
+			return db.Where(table + ".organisation_id IS NULL")
+		}
+		return db.Where(table+".organisation_id = ?", t.OrganisationID)
+	}
+}
+
+// visiblePrompts limits a prompt query to the global library plus the
+// tenant's private prompts
+func visiblePrompts(t Tenant) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if t.OrganisationID == "" {
+			return db.Where("prompts.organisation_id IS NULL")
+		}
+		return db.Where("prompts.organisation_id IS NULL OR prompts.organisation_id = ?", t.OrganisationID)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"speaktrainer-api/internal/models"
+)
+
+type UserService struct {
+	db *gorm.DB
+}
+
+func NewUserService(db *gorm.DB) *UserService {
+	return &UserService{db: db}
+}
+
+func (s *UserService) GetUserByID(id string) (*models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	return &user, nil
+}
+
+func IsValidRole(role string) bool {
+	return role == models.RoleLearner || role == models.RoleTeacher || role == models.RoleAdmin
+}
+
+func (s *UserService) CreateUser(email, name, role string) (*models.User, error) {
+	if !IsValidRole(role) {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+
+	user := &models.User{
+		ID:    uuid.New().String(),
+		Email: email,
+		Name:  name,
+		Role:  role,
+	}
+
+	if err := s.db.Create(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+// SetRole changes the role of the user with the given ID or email. It
+// returns nil when no such user exists.
+func (s *UserService) SetRole(idOrEmail, role string) (*models.User, error) {
+	if !IsValidRole(role) {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+
+	var user models.User
+	if err := s.db.First(&user, "id = ? OR email = ?", idOrEmail, idOrEmail).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	user.Role = role
+	if err := s.db.Save(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return &user, nil
+}
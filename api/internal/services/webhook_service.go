@@ -0,0 +1,494 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"speaktrainer-api/internal/models"
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	// webhookBatchSize is how many due deliveries one dispatch sends at once
+	webhookBatchSize = 20
+
+	// Retries wait webhookBaseBackoff, doubling per attempt, up to
+	// webhookMaxBackoff
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+
+	// maxDeliveryErrorLength keeps a receiver's error pages out of the log
+	maxDeliveryErrorLength = 500
+
+	// Finished deliveries stay in the log for webhookLogRetention
+	webhookLogRetention = 30 * 24 * time.Hour
+)
+
+var (
+	ErrWebhookNotFound = kindError(ErrNotFound, "webhook not found")
+	ErrInvalidWebhook  = kindError(ErrInvalidInput, "invalid webhook")
+)
+
+type WebhookService struct {
+	db          *gorm.DB
+	client      *http.Client
+	lookupIP    func(ctx context.Context, host string) ([]net.IP, error)
+	timeout     time.Duration
+	maxAttempts int
+}
+
+// NewWebhookService creates the webhook service. Receivers get timeout to
+// answer each delivery, and a delivery is given up after maxAttempts.
+func NewWebhookService(db *gorm.DB, timeout time.Duration, maxAttempts int) *WebhookService {
+	return &WebhookService{
+		db: db,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: otelhttp.NewTransport(webhookTransport(isPublicIP),
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return "webhook " + r.Method
+				}),
+			),
+			// A redirect could lead anywhere; receivers must answer themselves
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		lookupIP:    lookupIP,
+		timeout:     timeout,
+		maxAttempts: maxAttempts,
+	}
+}
+
+type CreateWebhookRequest struct {
+	Tenant Tenant
+	// OrganisationID is only needed, and only allowed, for the zero Tenant;
+	// otherwise webhooks belong to the tenant's organisation
+	OrganisationID *string
+	URL            string
+	Events         []string
+	CreatedBy      string
+}
+
+// CreateWebhook registers a subscription and returns it with the secret
+// its deliveries are signed with
+func (s *WebhookService) CreateWebhook(req CreateWebhookRequest) (*models.WebhookSubscription, string, error) {
+	orgID := req.Tenant.OrganisationID
+	if req.OrganisationID != nil {
+		if orgID != "" && orgID != *req.OrganisationID {
+			return nil, "", ErrOrganisationNotFound
+		}
+		orgID = *req.OrganisationID
+	}
+	if orgID == "" {
+		return nil, "", fmt.Errorf("%w: organisation_id is required", ErrInvalidWebhook)
+	}
+
+	if len(req.Events) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	for _, event := range req.Events {
+		if !isWebhookEvent(event) {
+			return nil, "", fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	target, err := checkWebhookURL(ctx, s.lookupIP, req.URL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Organisation{}).Where("id = ?", orgID).Count(&count).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to fetch organisation: %w", err)
+	}
+	if count == 0 {
+		return nil, "", ErrOrganisationNotFound
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	secret := "whsec_" + base64.RawURLEncoding.EncodeToString(buf)
+
+	sub := &models.WebhookSubscription{
+		ID:             uuid.New().String(),
+		OrganisationID: orgID,
+		URL:            target,
+		Secret:         secret,
+		Events:         req.Events,
+		CreatedBy:      req.CreatedBy,
+	}
+	if err := s.db.Create(sub).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return sub, secret, nil
+}
+
+func (s *WebhookService) ListWebhooks(tenant Tenant) ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+	if err := s.db.Scopes(managedWebhooks(tenant)).Order("created_at DESC").Find(&subs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks: %w", err)
+	}
+	return subs, nil
+}
+
+// DeleteWebhook removes a subscription along with its delivery log.
+// Pending deliveries are dropped.
+func (s *WebhookService) DeleteWebhook(tenant Tenant, id string) error {
+	result := s.db.Scopes(managedWebhooks(tenant)).Delete(&models.WebhookSubscription{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete webhook: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries returns a subscription's most recent deliveries, newest
+// first
+func (s *WebhookService) ListDeliveries(tenant Tenant, subscriptionID string, limit int) ([]models.WebhookDelivery, error) {
+	var count int64
+	if err := s.db.Model(&models.WebhookSubscription{}).Scopes(managedWebhooks(tenant)).Where("id = ?", subscriptionID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrWebhookNotFound
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := s.db.Where("subscription_id = ?", subscriptionID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// managedWebhooks limits a subscription query to those a tenant's admins
+// manage: every subscription for the zero Tenant, otherwise the
+// organisation's own
+func managedWebhooks(t Tenant) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if t.OrganisationID == "" {
+			return db
+		}
+		return db.Where("organisation_id = ?", t.OrganisationID)
+	}
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range models.WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookPayload is the JSON body of every delivery
+type webhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// enqueueWebhooks queues event for every subscription of orgID that wants
+// it. Pass the transaction making the change the event announces, so the
+// event is recorded exactly when the change is. Nothing is sent for data
+// outside an organisation.
+func enqueueWebhooks(tx *gorm.DB, orgID *string, event string, data interface{}) error {
+	if orgID == nil {
+		return nil
+	}
+
+	var subs []models.WebhookSubscription
+	if err := tx.Where("organisation_id = ?", *orgID).Find(&subs).Error; err != nil {
+		return fmt.Errorf("failed to fetch webhooks: %w", err)
+	}
+
+	now := time.Now()
+	payload := webhookPayload{ID: uuid.New().String(), Event: event, CreatedAt: now, Data: data}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, sub := range subs {
+		if !sub.HasEvent(event) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: sub.ID,
+			EventID:        payload.ID,
+			Event:          event,
+			Payload:        string(body),
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to queue webhooks: %w", err)
+	}
+	return nil
+}
+
+// RunDispatcher sends due deliveries every interval, and prunes the
+// delivery log hourly, until ctx is done
+func (s *WebhookService) RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Dispatch(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("webhook dispatch failed", "error", err)
+			}
+		case <-pruneTicker.C:
+			if err := s.PruneDeliveries(ctx, time.Now().Add(-webhookLogRetention)); err != nil && ctx.Err() == nil {
+				slog.Warn("webhook log prune failed", "error", err)
+			}
+		}
+	}
+}
+
+// PruneDeliveries deletes delivered and failed deliveries created before
+// cutoff. Pending ones are kept however old.
+func (s *WebhookService) PruneDeliveries(ctx context.Context, cutoff time.Time) error {
+	err := s.db.WithContext(ctx).
+		Where("status <> ? AND created_at < ?", models.DeliveryPending, cutoff).
+		Delete(&models.WebhookDelivery{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to prune webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// Dispatch sends every delivery that is due, a batch at a time
+func (s *WebhookService) Dispatch(ctx context.Context) error {
+	for ctx.Err() == nil {
+		due, err := s.claimDue(ctx)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		for i := range due {
+			wg.Add(1)
+			go func(d *models.WebhookDelivery) {
+				defer wg.Done()
+				s.deliver(ctx, d)
+			}(&due[i])
+		}
+		wg.Wait()
+	}
+	return ctx.Err()
+}
+
+// claimDue leases a batch of due deliveries by pushing their next attempt
+// past the time sending them can take, so other replicas skip them. If
+// this process dies mid-send they become due again once the lease ends.
+func (s *WebhookService) claimDue(ctx context.Context) ([]models.WebhookDelivery, error) {
+	now := time.Now()
+	lease := 2*s.timeout + time.Minute
+
+	var due []models.WebhookDelivery
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(webhookBatchSize).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(due))
+		for i, d := range due {
+			ids[i] = d.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	// Loaded after the lock is released rather than preloaded under it
+	ids := make([]string, len(due))
+	for i, d := range due {
+		ids[i] = d.SubscriptionID
+	}
+	var list []models.WebhookSubscription
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks: %w", err)
+	}
+	subs := make(map[string]*models.WebhookSubscription, len(list))
+	for i := range list {
+		subs[list[i].ID] = &list[i]
+	}
+	for i := range due {
+		due[i].Subscription = subs[due[i].SubscriptionID]
+	}
+
+	return due, nil
+}
+
+// deliver makes one attempt at a delivery and records the outcome
+func (s *WebhookService) deliver(ctx context.Context, d *models.WebhookDelivery) {
+	if d.Subscription == nil {
+		// The subscription was deleted; its deliveries go with it
+		return
+	}
+
+	status, err := s.send(ctx, d)
+	if ctx.Err() != nil {
+		// Shutting down: the attempt doesn't count and is retried once the
+		// lease ends
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"attempts":        d.Attempts + 1,
+		"last_attempt_at": now,
+		"response_status": nil,
+	}
+	if status != 0 {
+		updates["response_status"] = status
+	}
+
+	switch {
+	case err == nil:
+		updates["status"] = models.DeliveryDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	case d.Attempts+1 >= s.maxAttempts:
+		updates["status"] = models.DeliveryFailed
+		updates["last_error"] = truncate(err.Error(), maxDeliveryErrorLength)
+		slog.Warn("webhook delivery given up", "delivery_id", d.ID, "subscription_id", d.SubscriptionID, "attempts", d.Attempts+1, "error", err)
+	default:
+		updates["next_attempt_at"] = now.Add(webhookBackoff(d.Attempts + 1))
+		updates["last_error"] = truncate(err.Error(), maxDeliveryErrorLength)
+	}
+
+	if err := s.db.Model(&models.WebhookDelivery{}).Where("id = ?", d.ID).Updates(updates).Error; err != nil {
+		slog.Warn("failed to record webhook delivery", "delivery_id", d.ID, "error", err)
+	}
+}
+
+// send posts the payload and returns the receiver's status. Any status
+// outside 2xx is an error.
+func (s *WebhookService) send(ctx context.Context, d *models.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	// Subscriptions made before https was required aren't sent to
+	if target, err := url.Parse(d.Subscription.URL); err != nil || target.Scheme != "https" {
+		return 0, errors.New("webhook URL must use https")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Subscription.URL, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SpeakTrainer-Webhooks/1")
+	req.Header.Set(WebhookIDHeader, d.EventID)
+	req.Header.Set(WebhookEventHeader, d.Event)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(d.Subscription.Secret, timestamp, []byte(d.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature header value for a payload:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<payload>">". Receivers
+// recompute it with their secret and reject stale timestamps to stop
+// replays.
+func SignWebhook(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// webhookBackoff is the wait before retrying after attempt failures, with
+// up to 20% jitter so a receiver coming back isn't hit by every retry at
+// once
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookMaxBackoff
+	if attempts < 20 {
+		backoff = min(webhookBaseBackoff<<(attempts-1), webhookMaxBackoff)
+	}
+	return backoff + time.Duration(mathrand.Int63n(int64(backoff)/5+1))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
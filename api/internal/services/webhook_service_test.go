@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"speaktrainer-api/internal/models"
+)
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		secret    string
+		timestamp int64
+		payload   string
+		want      string
+	}{
+		{"whsec_test", 1700000000, `{"id":"evt-1"}`, "t=1700000000,v1=5056f09710e0bebdbcd623bb1a7714db4eac94f18745b31b96dd55a69f444e14"},
+		{"other", 1700000000, `{"id":"evt-1"}`, "t=1700000000,v1=9a1bab46d35c3c98d0c10250e2d3944f82c81f7f1a2eabb4a97f84b0878e7b49"},
+		{"whsec_test", 1700000001, `{"id":"evt-1"}`, "t=1700000001,v1=dd8ef1d2068e3575e52255dc85cb9df2686c41c7c842615edcc81e244b10ac53"},
+		{"whsec_test", 0, "", "t=0,v1=a2fa7a43c6a1cf2e784eaf3327d65c65b3d2b790320ebed9aa5661bc42a8cccd"},
+	}
+
+	for _, tt := range tests {
+		if got := SignWebhook(tt.secret, tt.timestamp, []byte(tt.payload)); got != tt.want {
+			t.Errorf("SignWebhook(%q, %d, %q) = %s, want %s", tt.secret, tt.timestamp, tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	hosts := map[string][]net.IP{
+		"hooks.example.com":    {net.ParseIP("93.184.216.34")},
+		"internal.example.com": {net.ParseIP("10.1.2.3")},
+		"mixed.example.com":    {net.ParseIP("93.184.216.34"), net.ParseIP("127.0.0.1")},
+	}
+	lookup := func(ctx context.Context, host string) ([]net.IP, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IP{ip}, nil
+		}
+		if ips, ok := hosts[host]; ok {
+			return ips, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://hooks.example.com/speaktrainer", true},
+		{"https://93.184.216.34:8443/hook", true},
+		{"http://hooks.example.com/speaktrainer", false},
+		{"ftp://hooks.example.com/", false},
+		{"/relative", false},
+		{"https://", false},
+		{"https://missing.example.com/", false},
+		{"https://internal.example.com/", false},
+		{"https://mixed.example.com/", false},
+		{"https://127.0.0.1/", false},
+		{"https://10.0.0.1/", false},
+		{"https://172.16.5.4/", false},
+		{"https://192.168.1.1/", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://0.0.0.0/", false},
+		{"https://[::1]/", false},
+		{"https://[fe80::1]/", false},
+		{"https://[fd00::1]/", false},
+		{"https://[::ffff:127.0.0.1]/", false},
+	}
+
+	for _, tt := range tests {
+		_, err := checkWebhookURL(context.Background(), lookup, tt.url)
+		if tt.ok && err != nil {
+			t.Errorf("checkWebhookURL(%s) = %v, want it accepted", tt.url, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("checkWebhookURL(%s) = %v, want it rejected", tt.url, err)
+		}
+	}
+}
+
+func TestSendRefusesPrivateReceivers(t *testing.T) {
+	var received atomic.Bool
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Store(true)
+	}))
+	defer receiver.Close()
+
+	tests := []struct {
+		name    string
+		allowed func(net.IP) bool
+		url     string
+		wantErr bool
+	}{
+		{"loopback receiver", isPublicIP, receiver.URL, true},
+		{"plain http", func(net.IP) bool { return true }, "http://" + receiver.Listener.Addr().String(), true},
+		{"allowed receiver", func(net.IP) bool { return true }, receiver.URL, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received.Store(false)
+			service := NewWebhookService(nil, time.Second, 1)
+			transport := webhookTransport(tt.allowed)
+			transport.TLSClientConfig = receiver.Client().Transport.(*http.Transport).TLSClientConfig
+			service.client.Transport = transport
+
+			_, err := service.send(context.Background(), &models.WebhookDelivery{
+				Payload:      "{}",
+				Subscription: &models.WebhookSubscription{URL: tt.url, Secret: "whsec_test"},
+			})
+			if (err != nil) != tt.wantErr || received.Load() == tt.wantErr {
+				t.Errorf("send = %v, received = %v, want error %v", err, received.Load(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// errPrivateTarget refuses a connection to an address that isn't public
+var errPrivateTarget = errors.New("webhook receivers must be on public addresses")
+
+// isPublicIP reports whether ip may receive webhooks. Loopback, private,
+// link-local and other non-routable addresses would let a subscriber make
+// the server call into its own network.
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// checkWebhookURL accepts an absolute https URL whose host resolves only to
+// public addresses, and returns it normalised
+func checkWebhookURL(ctx context.Context, lookup func(ctx context.Context, host string) ([]net.IP, error), raw string) (string, error) {
+	target, err := url.Parse(raw)
+	if err != nil || target.Scheme != "https" || target.Hostname() == "" {
+		return "", fmt.Errorf("%w: url must be an absolute https URL", ErrInvalidWebhook)
+	}
+
+	ips, err := lookup(ctx, target.Hostname())
+	if err != nil || len(ips) == 0 {
+		return "", fmt.Errorf("%w: url host %s does not resolve", ErrInvalidWebhook, target.Hostname())
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return "", fmt.Errorf("%w: url host %s is not a public address", ErrInvalidWebhook, target.Hostname())
+		}
+	}
+	return target.String(), nil
+}
+
+// lookupIP resolves host, which may already be an IP address
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// webhookTransport only connects to public addresses. The check is made on
+// the address actually dialled, so a hostname that resolved to a public
+// address when the webhook was created can't be pointed inside later.
+// Proxies are bypassed, since the check would then see the proxy's address.
+func webhookTransport(allowed func(net.IP) bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowed(ip) {
+				return fmt.Errorf("%w: %s", errPrivateTarget, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
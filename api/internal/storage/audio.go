@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AudioStore keeps the raw recordings behind sessions
+type AudioStore interface {
+	Save(key string, data []byte) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// FileAudioStore stores recordings as files in a single directory
+type FileAudioStore struct {
+	dir string
+}
+
+func NewFileAudioStore(dir string) (*FileAudioStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create audio directory: %w", err)
+	}
+	return &FileAudioStore{dir: dir}, nil
+}
+
+func (s *FileAudioStore) Save(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
+func (s *FileAudioStore) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	return f, nil
+}
+
+// Delete removes a recording. Deleting a missing recording is not an error.
+func (s *FileAudioStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete recording: %w", err)
+	}
+	return nil
+}
+
+// path rejects keys that would escape the store directory
+func (s *FileAudioStore) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid recording key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
@@ -0,0 +1,17 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Middleware starts a server span per request, continuing any trace the
+// caller propagated. Probes and metric scrapes are not traced.
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return !strings.HasPrefix(r.URL.Path, "/health") && r.URL.Path != "/metrics"
+	}))
+}
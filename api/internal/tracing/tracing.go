@@ -0,0 +1,94 @@
+// Package tracing configures OpenTelemetry tracing and W3C trace context
+// propagation
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"speaktrainer-api/internal/version"
+)
+
+// ServiceName identifies this API in traces
+const ServiceName = "speaktrainer-api"
+
+// Exporters accepted by Setup
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Options struct {
+	// Exporter is none, stdout or otlp
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://otel-collector:4318;
+	// empty falls back to the standard OTEL_EXPORTER_OTLP_* variables
+	Endpoint string
+	// SampleRatio is the fraction of new traces recorded; incoming sampled
+	// traces are always followed
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider and propagator. Trace context is
+// propagated even when exporting is off, so the ML service can still join
+// traces started upstream. The returned function flushes pending spans.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(opts.Exporter) {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+		semconv.ServiceVersion(version.Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer for spans created by this API
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
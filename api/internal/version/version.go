@@ -0,0 +1,10 @@
+// Package version exposes build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X speaktrainer-api/internal/version.Version=1.2.0 -X speaktrainer-api/internal/version.Commit=$(git rev-parse --short HEAD)"
+package version
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
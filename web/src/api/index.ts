@@ -1,6 +1,6 @@
 import type { AnalysisResult, Prompt } from "@/types";
 
-const API_BASE_URL = import.meta.env.VITE_API_URL || "http://localhost:8000/api";
+const API_BASE_URL = import.meta.env.VITE_API_URL || "http://localhost:8000/api/v1";
 
 export const fetchPrompts = async (): Promise<{ prompts: Prompt[] }> => {
   const response = await fetch(`${API_BASE_URL}/prompts`);
@@ -55,7 +55,7 @@ export const analyzeAudio = async (audioFile: File, expectedText: string): Promi
 
   if (!response.ok) {
     const errorData = await response.json();
-    throw new Error(errorData.error || 'Analysis failed');
+    throw new Error(errorData.error?.message || 'Analysis failed');
   }
 
   return response.json();